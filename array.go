@@ -1,5 +1,10 @@
 package jcs
 
+import (
+	"reflect"
+	"unsafe"
+)
+
 // appendSlice appends the canonical JSON representation of a Go slice to dst.
 //
 // This function implements array serialization rules required by RFC 8785
@@ -17,29 +22,120 @@ package jcs
 //     length and returns the error.
 //
 // Error handling:
-//   - Returns any error produced by Append when encoding an element.
+//   - Returns any error produced by Append when encoding an element, as an
+//     *EncodeError whose Path starts with the index of the element.
+//   - Returns ErrCycle if arr is nested in one of its own elements.
 //   - Common errors include ErrUnsupportedType, ErrNaN, ErrInf, ErrInvalidUTF8,
 //     or ErrNumberOOR depending on the element type.
 //   - If an error occurs, no partial array is returned; dst is reset to its
 //     original state before appendSlice was called.
 //
+// appendSlice is a function rather than a method of Encoder because methods
+// cannot have type parameters; elements are encoded with the configuration
+// of e.
+//
 // The resulting output is guaranteed to be a valid, canonical JSON array
 // according to RFC 8785, with each element individually validated and encoded.
-func appendSlice[T any](dst []byte, arr []T) ([]byte, error) {
-	dstLen := len(dst)
+func appendSlice[T any](e *Encoder, dst []byte, arr []T) ([]byte, error) {
+	if err := e.enter(); err != nil {
+		return dst, err
+	}
+	defer e.leave()
+
+	ptr := unsafe.Pointer(unsafe.SliceData(arr))
+	if err := e.enterRef(ptr, len(arr)); err != nil {
+		return dst, err
+	}
+	defer e.leaveRef(ptr, len(arr))
+
+	dstLen := e.offset(dst)
 	dst = append(dst, '[')
 
 	for i, v := range arr {
-		if i > 0 {
+		var err error
+		if dst, err = e.flush(dst); err != nil {
+			return dst, err
+		}
+
+		start := e.offset(dst)
+		if start > dstLen+1 {
 			dst = append(dst, ',')
 		}
 
+		mark := len(e.violations)
+
+		dst, err = e.append(dst, v)
+		if err != nil || len(e.violations) > mark {
+			if e.dropped(err) {
+				dst = e.truncate(dst, start)
+				continue
+			}
+			if err = e.elemError(err, mark, i, reflect.TypeOf(v)); err != nil {
+				dst = e.truncate(dst, dstLen)
+				return dst, err
+			}
+		}
+	}
+
+	dst = append(dst, ']')
+	return dst, nil
+}
+
+// appendArray appends the canonical JSON representation of a slice or array
+// value of any element type, such as []map[string]any, [3]float64 or a named
+// `type Tags []string`, to dst.
+//
+// It is the reflection counterpart of appendSlice and follows the same rules:
+// elements are encoded in order with Append, separated by ',' and enclosed in
+// '[' and ']'. A nil slice is encoded as [] just like an empty []any.
+//
+// Error handling:
+//   - Returns any error produced by Append when encoding an element, as an
+//     *EncodeError like appendSlice.
+//   - If an error occurs, dst is reset to its original state.
+func (e *Encoder) appendArray(dst []byte, v reflect.Value) ([]byte, error) {
+	if err := e.enter(); err != nil {
+		return dst, err
+	}
+	defer e.leave()
+
+	// an array is a value, only a slice can be part of a cycle
+	if v.Kind() == reflect.Slice {
+		ptr := v.UnsafePointer()
+		if err := e.enterRef(ptr, v.Len()); err != nil {
+			return dst, err
+		}
+		defer e.leaveRef(ptr, v.Len())
+	}
+
+	dstLen := e.offset(dst)
+	dst = append(dst, '[')
+
+	for i := 0; i < v.Len(); i++ {
 		var err error
-		dst, err = Append(dst, v)
-		if err != nil {
-			dst = dst[:dstLen]
+		if dst, err = e.flush(dst); err != nil {
 			return dst, err
 		}
+
+		start := e.offset(dst)
+		if start > dstLen+1 {
+			dst = append(dst, ',')
+		}
+
+		mark := len(e.violations)
+
+		elem := v.Index(i)
+		dst, err = e.appendValue(dst, elem)
+		if err != nil || len(e.violations) > mark {
+			if e.dropped(err) {
+				dst = e.truncate(dst, start)
+				continue
+			}
+			if err = e.elemError(err, mark, i, elem.Type()); err != nil {
+				dst = e.truncate(dst, dstLen)
+				return dst, err
+			}
+		}
 	}
 
 	dst = append(dst, ']')
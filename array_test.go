@@ -1,6 +1,7 @@
 package jcs
 
 import (
+	"errors"
 	"fmt"
 	"testing"
 	"time"
@@ -20,14 +21,15 @@ func TestAppendSlice(t *testing.T) {
 		{"Booleans", []any{true, false}, "[true,false]", nil},
 		{"Times", []any{time.Date(2019, 1, 28, 7, 45, 10, 0, time.UTC), time.Date(2019, 1, 28, 7, 45, 10, 123456000, time.UTC)}, `["2019-01-28T07:45:10Z","2019-01-28T07:45:10.123456Z"]`, nil},
 		{"MixedTypes", []any{"hi", 42, true}, "[\"hi\",42,true]", nil},
+		{"Errors", []any{fmt.Errorf("fail")}, "[{}]", nil},
 		// unsupported types
-		{"ErrorUnsupportedTypes", []any{fmt.Errorf("fail")}, "", ErrUnsupportedType},
+		{"ErrorUnsupportedTypes", []any{make(chan int)}, "", ErrUnsupportedType},
 	}
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			out, err := appendSlice([]byte{}, tc.value)
-			Equals(t, tc.wantErr, err)
+			out, err := appendSlice(NewEncoder(), []byte{}, tc.value)
+			Equals(t, true, errors.Is(err, tc.wantErr))
 			Equals(t, tc.want, string(out))
 		})
 	}
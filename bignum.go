@@ -0,0 +1,248 @@
+package jcs
+
+import (
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// WithLargeNumbersAsStrings makes the encoder write numbers that cannot be
+// represented exactly as IEEE‑754 doubles as JSON strings holding their
+// canonical decimal form, instead of failing with ErrNumberOOR or
+// ErrNumberPrecision. This is the convention recommended by I-JSON
+// (RFC 7493 section 2.2) for large identifiers and amounts; the output is
+// still canonical JSON, but consumers must know which strings are numbers.
+//
+// It applies to:
+//   - integer types beyond ±MaxSafeNumber and json.Number integers that a
+//     double would round, e.g. "9223372036854775807";
+//   - *big.Int values beyond ±MaxSafeNumber, in plain decimal digits;
+//   - *big.Float values that are not exact doubles, in the shortest decimal
+//     form that identifies them at their precision, laid out like numbers;
+//   - *big.Rat values that are not exact doubles but have a finite decimal
+//     expansion, e.g. "0.1"; others, such as 1/3, still fail with
+//     ErrNumberPrecision.
+func WithLargeNumbersAsStrings() Option {
+	return func(e *Encoder) {
+		e.largeNumbersAsStrings = true
+	}
+}
+
+// appendLargeInt handles the integer v that is beyond ±MaxSafeNumber: it is
+// written as a decimal string when e is configured with
+// WithLargeNumbersAsStrings, and rejected with ErrNumberOOR otherwise.
+func (e *Encoder) appendLargeInt(dst []byte, v int64) ([]byte, error) {
+	if !e.largeNumbersAsStrings {
+		return dst, ErrNumberOOR
+	}
+
+	dst = append(dst, '"')
+	dst = strconv.AppendInt(dst, v, 10)
+	return append(dst, '"'), nil
+}
+
+// appendLargeUint is appendLargeInt for unsigned integers.
+func (e *Encoder) appendLargeUint(dst []byte, v uint64) ([]byte, error) {
+	if !e.largeNumbersAsStrings {
+		return dst, ErrNumberOOR
+	}
+
+	dst = append(dst, '"')
+	dst = strconv.AppendUint(dst, v, 10)
+	return append(dst, '"'), nil
+}
+
+// appendNumberLiteral is appendNumberLiteral for the configuration of e:
+// integer literals that a double would round are written as strings when
+// e is configured with WithLargeNumbersAsStrings. The grammar of integer
+// literals leaves them in canonical form already.
+func (e *Encoder) appendNumberLiteral(dst []byte, s string) ([]byte, error) {
+	dst, err := appendNumberLiteral(dst, s)
+	if err == ErrNumberOOR && e.largeNumbersAsStrings {
+		if integer, _ := scanNumberLiteral(s); integer {
+			dst = append(dst, '"')
+			dst = append(dst, s...)
+			return append(dst, '"'), nil
+		}
+	}
+
+	return dst, err
+}
+
+// appendBigInt appends the integer x as a canonical JSON number, like an
+// int64 of the same value. A nil x is encoded as null.
+//
+// Error handling:
+//   - Returns ErrNumberOOR if x is beyond ±MaxSafeNumber, unless e is
+//     configured with WithLargeNumbersAsStrings.
+func (e *Encoder) appendBigInt(dst []byte, x *big.Int) ([]byte, error) {
+	if x == nil {
+		return append(dst, 'n', 'u', 'l', 'l'), nil
+	}
+
+	if x.IsInt64() && !isNumberOOR(x.Int64()) {
+		return appendNumber(dst, float64(x.Int64()))
+	}
+
+	if !e.largeNumbersAsStrings {
+		return dst, ErrNumberOOR
+	}
+
+	dst = append(dst, '"')
+	dst = x.Append(dst, 10)
+	return append(dst, '"'), nil
+}
+
+// appendBigFloat appends x as a canonical JSON number if it is exactly
+// representable as a double, whatever its precision. A nil x is encoded as
+// null, and ±Inf is treated like the float64 infinities.
+//
+// Error handling:
+//   - Returns ErrNumberOOR if the magnitude of x overflows a double, and
+//     ErrNumberPrecision if x has more significant bits than a double or
+//     underflows, unless e is configured with WithLargeNumbersAsStrings.
+func (e *Encoder) appendBigFloat(dst []byte, x *big.Float) ([]byte, error) {
+	if x == nil {
+		return append(dst, 'n', 'u', 'l', 'l'), nil
+	}
+
+	f, acc := x.Float64()
+	if acc == big.Exact || x.IsInf() {
+		return e.appendFloat(dst, f)
+	}
+
+	if e.largeNumbersAsStrings {
+		dst = append(dst, '"')
+		dst = appendBigFloatText(dst, x)
+		return append(dst, '"'), nil
+	}
+
+	if math.IsInf(f, 0) {
+		return dst, ErrNumberOOR
+	}
+	return dst, ErrNumberPrecision
+}
+
+// appendBigRat appends x as a canonical JSON number if it is exactly
+// representable as a double. A nil x is encoded as null.
+//
+// Error handling:
+//   - Returns ErrNumberOOR if the magnitude of x overflows a double, and
+//     ErrNumberPrecision if it cannot be represented exactly, e.g. 1/3 or
+//     1/10. With WithLargeNumbersAsStrings, a value with a finite decimal
+//     expansion such as 1/10 is written as the string "0.1" instead.
+func (e *Encoder) appendBigRat(dst []byte, x *big.Rat) ([]byte, error) {
+	if x == nil {
+		return append(dst, 'n', 'u', 'l', 'l'), nil
+	}
+
+	f, exact := x.Float64()
+	if exact {
+		return appendNumber(dst, f)
+	}
+
+	if e.largeNumbersAsStrings {
+		if digits, ok := decimalDigits(x); ok {
+			dst = append(dst, '"')
+			dst = append(dst, x.FloatString(digits)...)
+			return append(dst, '"'), nil
+		}
+	}
+
+	if math.IsInf(f, 0) {
+		return dst, ErrNumberOOR
+	}
+	return dst, ErrNumberPrecision
+}
+
+// decimalDigits returns the number of fractional digits of the decimal
+// expansion of x, and reports whether it is finite, i.e. whether the
+// denominator of x has no prime factors other than 2 and 5.
+func decimalDigits(x *big.Rat) (int, bool) {
+	d := new(big.Int).Set(x.Denom())
+
+	twos := int(d.TrailingZeroBits())
+	d.Rsh(d, uint(twos))
+
+	var fives int
+	five, m := big.NewInt(5), new(big.Int)
+	for {
+		q, r := new(big.Int).QuoRem(d, five, m)
+		if r.Sign() != 0 {
+			break
+		}
+		d = q
+		fives++
+	}
+
+	return max(twos, fives), d.IsInt64() && d.Int64() == 1
+}
+
+// appendBigFloatText appends the shortest decimal that identifies x at its
+// precision, laid out with the ECMAScript rules of appendNumber: plain
+// notation for magnitudes in [1e-6, 1e21), exponent notation otherwise.
+func appendBigFloatText(dst []byte, x *big.Float) []byte {
+	return appendExponential(dst, x.Text('e', -1))
+}
+
+// appendExponential appends the number s, written in the exponent notation
+// d.ddddde±XX of strconv and math/big with no trailing zeros in its
+// mantissa, laid out by appendDecimal.
+func appendExponential(dst []byte, s string) []byte {
+	if s[0] == '-' {
+		dst = append(dst, '-')
+		s = s[1:]
+	}
+
+	mantissa, exp, _ := strings.Cut(s, "e")
+	digits := strings.Replace(mantissa, ".", "", 1)
+	n, _ := strconv.Atoi(exp)
+
+	return appendDecimal(dst, digits, n+1)
+}
+
+// appendDecimal appends the non-negative number 0.digits × 10^n, where
+// digits has no leading or trailing zeros, with the layout of the
+// ECMAScript Number::toString algorithm used by appendNumber.
+func appendDecimal(dst []byte, digits string, n int) []byte {
+	k := len(digits)
+
+	switch {
+	case k <= n && n <= 21:
+		// integer: digits followed by n−k zeros
+		dst = append(dst, digits...)
+		for range n - k {
+			dst = append(dst, '0')
+		}
+
+	case 0 < n && n <= 21:
+		// decimal point within the digits
+		dst = append(dst, digits[:n]...)
+		dst = append(dst, '.')
+		dst = append(dst, digits[n:]...)
+
+	case -6 < n && n <= 0:
+		// leading zeros after the decimal point
+		dst = append(dst, '0', '.')
+		for range -n {
+			dst = append(dst, '0')
+		}
+		dst = append(dst, digits...)
+
+	default:
+		// exponent notation, d[.ddd]e±x with an explicit sign
+		dst = append(dst, digits[0])
+		if k > 1 {
+			dst = append(dst, '.')
+			dst = append(dst, digits[1:]...)
+		}
+		dst = append(dst, 'e')
+		if n > 0 {
+			dst = append(dst, '+')
+		}
+		dst = strconv.AppendInt(dst, int64(n-1), 10)
+	}
+
+	return dst
+}
@@ -0,0 +1,142 @@
+package jcs
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"math/big"
+	"testing"
+)
+
+func bigFloat(s string, prec uint) *big.Float {
+	f, _, err := big.ParseFloat(s, 10, prec, big.ToNearestEven)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+func bigInt(s string) *big.Int {
+	i, _ := new(big.Int).SetString(s, 10)
+	return i
+}
+
+type bigLedger struct {
+	Amount  *big.Rat `json:"amount"`
+	Balance big.Int  `json:"balance"`
+	ID      uint64   `json:"id"`
+}
+
+func TestAppendBigNumbers(t *testing.T) {
+	testCases := []struct {
+		name    string
+		value   any
+		want    string
+		wantErr error
+	}{
+		{name: "IntSmall", value: big.NewInt(-42), want: `-42`},
+		{name: "IntMaxSafe", value: big.NewInt(MaxSafeNumber), want: `9007199254740991`},
+		{name: "IntValue", value: *big.NewInt(7), want: `7`},
+		{name: "IntNil", value: (*big.Int)(nil), want: `null`},
+		{name: "IntOOR", value: big.NewInt(MaxSafeNumber + 1), wantErr: ErrNumberOOR},
+		{name: "IntHuge", value: bigInt("123456789012345678901234567890"), wantErr: ErrNumberOOR},
+
+		{name: "Float", value: big.NewFloat(1.5), want: `1.5`},
+		{name: "FloatHighPrecExact", value: bigFloat("0.5", 200), want: `0.5`},
+		{name: "FloatLargeExact", value: bigFloat("1e21", 53), want: `1e+21`},
+		{name: "FloatInexact", value: bigFloat("0.1", 200), wantErr: ErrNumberPrecision},
+		{name: "FloatOverflow", value: bigFloat("1e400", 53), wantErr: ErrNumberOOR},
+		{name: "FloatInf", value: new(big.Float).SetInf(true), wantErr: ErrInf},
+		{name: "FloatNil", value: (*big.Float)(nil), want: `null`},
+
+		{name: "Rat", value: big.NewRat(3, 4), want: `0.75`},
+		{name: "RatInteger", value: big.NewRat(10, 2), want: `5`},
+		{name: "RatInexact", value: big.NewRat(1, 10), wantErr: ErrNumberPrecision},
+		{name: "RatNil", value: (*big.Rat)(nil), want: `null`},
+
+		{name: "Struct", value: bigLedger{Amount: big.NewRat(1, 2), Balance: *big.NewInt(100), ID: 1}, want: `{"amount":0.5,"balance":100,"id":1}`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Append(nil, tc.value)
+			Equals(t, true, errors.Is(err, tc.wantErr))
+			Equals(t, tc.want, string(got))
+		})
+	}
+}
+
+func TestLargeNumbersAsStrings(t *testing.T) {
+	testCases := []struct {
+		name    string
+		value   any
+		want    string
+		wantErr error
+	}{
+		{name: "Int64", value: int64(math.MaxInt64), want: `"9223372036854775807"`},
+		{name: "Int64Min", value: int64(math.MinInt64), want: `"-9223372036854775808"`},
+		{name: "Int", value: -int(MaxSafeNumber + 1), want: `"-9007199254740992"`},
+		{name: "Uint64", value: uint64(math.MaxUint64), want: `"18446744073709551615"`},
+		{name: "Uint", value: uint(MaxSafeNumber + 1), want: `"9007199254740992"`},
+		{name: "SafeStaysNumber", value: int64(MaxSafeNumber), want: `9007199254740991`},
+		{name: "NamedInt", value: []bigID{1 << 60}, want: `["1152921504606846976"]`},
+
+		{name: "NumberInteger", value: json.Number("-123456789012345678901234567890"), want: `"-123456789012345678901234567890"`},
+		{name: "NumberSafe", value: json.Number("1.50"), want: `1.5`},
+		{name: "NumberFloatOverflow", value: json.Number("1e400"), wantErr: ErrNumberOOR},
+		{name: "NumberPrecision", value: json.Number("0.10000000000000000001"), wantErr: ErrNumberPrecision},
+
+		{name: "BigInt", value: bigInt("123456789012345678901234567890"), want: `"123456789012345678901234567890"`},
+		{name: "BigIntSafe", value: big.NewInt(12), want: `12`},
+
+		{name: "BigFloat", value: bigFloat("0.1", 200), want: `"0.1"`},
+		{name: "BigFloatInteger", value: bigFloat("9007199254740993", 64), want: `"9007199254740993"`},
+		{name: "BigFloatLarge", value: bigFloat("1.25e400", 64), want: `"1.25e+400"`},
+		{name: "BigFloatSmall", value: bigFloat("-1.5e-7", 100), want: `"-1.5e-7"`},
+		{name: "BigFloatExact", value: big.NewFloat(2.5), want: `2.5`},
+
+		{name: "BigRat", value: big.NewRat(1, 10), want: `"0.1"`},
+		{name: "BigRatInteger", value: new(big.Rat).SetInt(bigInt("9007199254740993")), want: `"9007199254740993"`},
+		{name: "BigRatFraction", value: big.NewRat(-3, 80), want: `"-0.0375"`},
+		{name: "BigRatInfinite", value: big.NewRat(1, 3), wantErr: ErrNumberPrecision},
+
+		{name: "Ledger", value: map[string]any{"id": uint64(1<<63 + 1), "amount": big.NewRat(1, 5)}, want: `{"amount":"0.2","id":"9223372036854775809"}`},
+	}
+
+	e := NewEncoder(WithLargeNumbersAsStrings())
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := e.Append(nil, tc.value)
+			Equals(t, true, errors.Is(err, tc.wantErr))
+			Equals(t, tc.want, string(got))
+		})
+	}
+}
+
+type bigID uint64
+
+func TestAppendDecimal(t *testing.T) {
+	testCases := []struct {
+		digits string
+		n      int
+		want   string
+	}{
+		{"1", 1, "1"},
+		{"123", 3, "123"},
+		{"123", 5, "12300"},
+		{"1", 21, "100000000000000000000"},
+		{"1", 22, "1e+21"},
+		{"125", 1, "1.25"},
+		{"125", 0, "0.125"},
+		{"125", -5, "0.00000125"},
+		{"125", -6, "1.25e-7"},
+		{"3", -320, "3e-321"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.want, func(t *testing.T) {
+			Equals(t, tc.want, string(appendDecimal(nil, tc.digits, tc.n)))
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package jcs
+
+import (
+	"encoding/base64"
+	"reflect"
+)
+
+// BytesFormat selects how an Encoder writes byte slices and arrays, see
+// WithBytesFormat. RFC 8785 has no notion of binary data: every format
+// produces an ordinary JSON array or string, so producer and verifier must
+// agree on it.
+type BytesFormat int
+
+const (
+	// BytesArray writes bytes as a JSON array of numbers, such as
+	// [222,173,190,239]. This is the default.
+	BytesArray BytesFormat = iota
+
+	// BytesBase64 writes bytes as a string in standard padded base64
+	// (RFC 4648 section 4), such as "3q2+7w==", like encoding/json does.
+	BytesBase64
+
+	// BytesBase64URL writes bytes as a string in unpadded URL-safe base64
+	// (RFC 4648 section 5), such as "3q2-7w", as used by JOSE.
+	BytesBase64URL
+
+	// BytesHex writes bytes as a string of lower-case hexadecimal digits,
+	// such as "deadbeef".
+	BytesHex
+)
+
+// WithBytesFormat makes the encoder write []byte values in format f instead
+// of BytesArray. It also applies to other slices and arrays whose element
+// kind is uint8, such as a named `type Hash [32]byte`, so that digests and
+// nonces are written as compact strings. A nil slice is written as null,
+// like encoding/json does, and an empty one as "".
+func WithBytesFormat(f BytesFormat) Option {
+	return func(e *Encoder) {
+		e.bytesFormat = f
+	}
+}
+
+// appendBytes appends b in the bytes format of e.
+func (e *Encoder) appendBytes(dst []byte, b []byte) ([]byte, error) {
+	if b == nil && e.bytesFormat != BytesArray {
+		return append(dst, 'n', 'u', 'l', 'l'), nil
+	}
+
+	var enc *base64.Encoding
+
+	switch e.bytesFormat {
+	case BytesBase64:
+		enc = base64.StdEncoding
+
+	case BytesBase64URL:
+		enc = base64.RawURLEncoding
+
+	case BytesHex:
+		dst = append(dst, '"')
+		for _, c := range b {
+			dst = append(dst, hex[c>>4], hex[c&0xf])
+		}
+		return append(dst, '"'), nil
+
+	default:
+		return appendSlice(e, dst, b)
+	}
+
+	// neither alphabet contains characters that need escaping
+	dst = append(dst, '"')
+	dst = enc.AppendEncode(dst, b)
+	return append(dst, '"'), nil
+}
+
+// appendByteArray is appendBytes for a slice or array v whose element kind
+// is uint8 but whose type is not []byte.
+func (e *Encoder) appendByteArray(dst []byte, v reflect.Value) ([]byte, error) {
+	if v.Kind() == reflect.Slice {
+		return e.appendBytes(dst, v.Bytes())
+	}
+
+	// arrays are not addressable in general, copy them
+	b := make([]byte, v.Len())
+	for i := range b {
+		b[i] = byte(v.Index(i).Uint())
+	}
+	return e.appendBytes(dst, b)
+}
@@ -0,0 +1,52 @@
+package jcs
+
+import (
+	"crypto/sha256"
+	"testing"
+)
+
+type digest [4]byte
+
+type blob []byte
+
+type signedRecord struct {
+	Nonce []byte  `json:"nonce"`
+	Hash  *digest `json:"hash"`
+}
+
+func TestEncoderBytesFormat(t *testing.T) {
+	b := []byte{0xde, 0xad, 0xbe, 0xef}
+
+	testCases := []struct {
+		name  string
+		opts  []Option
+		value any
+		want  string
+	}{
+		{name: "Default", value: b, want: `[222,173,190,239]`},
+		{name: "DefaultArray", value: digest{1, 2, 3, 4}, want: `[1,2,3,4]`},
+		{name: "Base64", opts: []Option{WithBytesFormat(BytesBase64)}, value: b, want: `"3q2+7w=="`},
+		{name: "Base64URL", opts: []Option{WithBytesFormat(BytesBase64URL)}, value: b, want: `"3q2-7w"`},
+		{name: "Hex", opts: []Option{WithBytesFormat(BytesHex)}, value: b, want: `"deadbeef"`},
+		{name: "Empty", opts: []Option{WithBytesFormat(BytesBase64)}, value: []byte{}, want: `""`},
+		{name: "Nil", opts: []Option{WithBytesFormat(BytesHex)}, value: []byte(nil), want: `null`},
+		{name: "NilBase64", opts: []Option{WithBytesFormat(BytesBase64)}, value: []byte(nil), want: `null`},
+		{name: "NilNamed", opts: []Option{WithBytesFormat(BytesBase64URL)}, value: blob(nil), want: `null`},
+		{name: "NilDefault", value: []byte(nil), want: `[]`},
+
+		{name: "Named", opts: []Option{WithBytesFormat(BytesHex)}, value: blob(b), want: `"deadbeef"`},
+		{name: "Array", opts: []Option{WithBytesFormat(BytesHex)}, value: digest{0xde, 0xad, 0xbe, 0xef}, want: `"deadbeef"`},
+		{name: "Digest", opts: []Option{WithBytesFormat(BytesBase64URL)}, value: sha256.Sum256(nil), want: `"47DEQpj8HBSa-_TImW-5JCeuQeRkm5NMpJWZG3hSuFU"`},
+		{name: "Nested", opts: []Option{WithBytesFormat(BytesBase64)}, value: map[string]any{"k": [][]byte{b, nil, {}}}, want: `{"k":["3q2+7w==",null,""]}`},
+		{name: "Struct", opts: []Option{WithBytesFormat(BytesHex)}, value: signedRecord{Nonce: b[:2], Hash: &digest{0, 1, 2, 255}}, want: `{"hash":"000102ff","nonce":"dead"}`},
+		{name: "OtherSlices", opts: []Option{WithBytesFormat(BytesHex)}, value: []int8{1, -1}, want: `[1,-1]`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := NewEncoder(tc.opts...).Append(nil, tc.value)
+			Equals(t, nil, err)
+			Equals(t, tc.want, string(got))
+		})
+	}
+}
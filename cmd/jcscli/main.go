@@ -1,10 +1,16 @@
 package main
 
 import (
+	"bufio"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"math"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/Kbgjtn/jcs"
@@ -24,6 +30,69 @@ func fatal(quiet bool, msg string, err error, code int) {
 	os.Exit(code)
 }
 
+// writeOutput calls write with the output file at path, or stdout if path
+// is empty, followed by a newline on stdout. A file is written to a
+// temporary file in the same directory which replaces it only once write
+// succeeds, so that a failure never leaves a truncated output behind.
+func writeOutput(path string, write func(w io.Writer) error) error {
+	if path == "" {
+		w := bufio.NewWriter(os.Stdout)
+		if err := write(w); err != nil {
+			return err
+		}
+		if err := w.WriteByte('\n'); err != nil {
+			return err
+		}
+		return w.Flush()
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) //nolint
+
+	if err := write(f); err != nil {
+		f.Close() //nolint
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(f.Name(), 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
+
+// parseSize parses a positive number of bytes, optionally followed by one
+// of the binary multiples K, M or G.
+func parseSize(s string) (int, error) {
+	shift := 0
+	switch strings.ToUpper(s[len(s)-1:]) {
+	case "K":
+		shift = 10
+	case "M":
+		shift = 20
+	case "G":
+		shift = 30
+	}
+	if shift > 0 {
+		s = s[:len(s)-1]
+	}
+
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, err
+	}
+	if n <= 0 || n > math.MaxInt>>shift {
+		return 0, fmt.Errorf("size %s out of range", s)
+	}
+
+	return n << shift, nil
+}
+
 func usage() {
 	fmt.Fprintf(os.Stderr, `Usage: jcscli [options]
 
@@ -33,6 +102,11 @@ Options:
   -o, --output <path>     Path to output file (defaults to stdout)
   -w, --overwrite         Allow overwriting existing output file
   -p, --pretty            Pretty-print the canonical JSON output
+  -m, --memory <size>     Sort the members of a top-level object in runs of
+                          about <size> bytes (e.g. 512M, 2G) spilled to
+                          temporary files, for objects larger than memory
+  -t, --temp-dir <path>   Directory of the temporary files (defaults to the
+                          system temporary directory)
   -q, --quiet             Suppress non-fatal messages
   -v, --verbose           Print extra diagnostic information
   -h, --help              Show this help message
@@ -57,6 +131,12 @@ func main() {
 	pretty := flag.Bool("pretty", false, "")
 	flag.BoolVar(pretty, "p", false, "")
 
+	memory := flag.String("memory", "", "")
+	flag.StringVar(memory, "m", "", "")
+
+	tempDir := flag.String("temp-dir", "", "")
+	flag.StringVar(tempDir, "t", "", "")
+
 	quiet := flag.Bool("quiet", false, "")
 	flag.BoolVar(quiet, "q", false, "")
 
@@ -92,6 +172,19 @@ func main() {
 		}
 	}
 
+	// External sort of large top-level objects
+	var opts []jcs.Option
+	if *memory != "" {
+		budget, err := parseSize(*memory)
+		if err != nil {
+			fatal(*quiet, "Invalid memory budget", err, 2)
+		}
+		opts = append(opts, jcs.WithMemoryBudget(budget))
+	}
+	if *tempDir != "" {
+		opts = append(opts, jcs.WithTempDir(*tempDir))
+	}
+
 	start := time.Now()
 
 	// Choose input source
@@ -112,61 +205,77 @@ func main() {
 		reader = os.Stdin
 	}
 
-	// Decode JSON
-	decodeStart := time.Now()
-	var v any
-	if err := jsoniter.ConfigCompatibleWithStandardLibrary.NewDecoder(reader).Decode(&v); err != nil {
-		fatal(*quiet, "Invalid JSON", err, 1)
+	// Refuse to replace an existing output file
+	if *outputPath != "" && !*overwrite {
+		if _, err := os.Stat(*outputPath); err == nil && !*quiet {
+			fatal(*quiet, fmt.Sprintf("Output file %s already exists. Use --overwrite/-w to replace it.", *outputPath), nil, 1)
+		}
 	}
-	decodeElapsed := time.Since(decodeStart)
 
-	// Pre‑allocate buffer
-	bufCap := 1024 * 1024
-	if inputSize > 0 {
-		bufCap = int(inputSize) * 2
-	}
-	out := make([]byte, 0, bufCap)
+	var (
+		bufCap                     int
+		readElapsed, encodeElapsed time.Duration
+		writeElapsed               time.Duration
+	)
 
-	// Canonicalize with JCS
-	encodeStart := time.Now()
-	out, err := jcs.Append(out, v)
-	if err != nil {
-		fatal(*quiet, "Encoding error", err, 1)
-	}
-	encodeElapsed := time.Since(encodeStart)
-
-	// Pretty-print if requested
-	var final []byte
 	if *pretty {
+		// Read JSON
+		readStart := time.Now()
+		in, err := io.ReadAll(reader)
+		if err != nil {
+			fatal(*quiet, "Failed to read input", err, 1)
+		}
+		readElapsed = time.Since(readStart)
+
+		// Pre‑allocate buffer, canonical output is rarely much larger than the
+		// input since whitespace is removed
+		bufCap = len(in) + len(in)/8
+		out := make([]byte, 0, bufCap)
+
+		// Canonicalize with JCS
+		encodeStart := time.Now()
+		out, err = jcs.Transform(out, in)
+		if err != nil {
+			fatal(*quiet, "Invalid JSON", err, 1)
+		}
+		encodeElapsed = time.Since(encodeStart)
+
+		// Pretty-print
 		var prettyBuf any
 		if err := jsoniter.Unmarshal(out, &prettyBuf); err != nil {
 			fatal(*quiet, "Pretty-print error", err, 1)
 		}
-		final, err = jsoniter.MarshalIndent(prettyBuf, "", "  ")
+		final, err := jsoniter.MarshalIndent(prettyBuf, "", "  ")
 		if err != nil {
 			fatal(*quiet, "Pretty-print error", err, 1)
 		}
-	} else {
-		final = out
-	}
 
-	// Write output
-	writeStart := time.Now()
-	if *outputPath != "" {
-		if !*overwrite {
-			if _, err := os.Stat(*outputPath); err == nil {
-				if !*quiet {
-					fatal(*quiet, fmt.Sprintf("Output file %s already exists. Use --overwrite/-w to replace it.", *outputPath), nil, 1)
-				}
-			}
-		}
-		if err := os.WriteFile(*outputPath, final, 0o644); err != nil {
-			fatal(*quiet, "Failed to write output file", err, 1)
+		// Write output
+		writeStart := time.Now()
+		err = writeOutput(*outputPath, func(w io.Writer) error {
+			_, err := w.Write(final)
+			return err
+		})
+		if err != nil {
+			fatal(*quiet, "Failed to write output", err, 1)
 		}
+		writeElapsed = time.Since(writeStart)
 	} else {
-		fmt.Println(string(final))
+		// Canonicalize with JCS while reading and writing, so that inputs
+		// made of a huge top-level array never have to fit in memory
+		encodeStart := time.Now()
+		err := writeOutput(*outputPath, func(w io.Writer) error {
+			return jcs.TransformStream(w, reader, opts...)
+		})
+		if err != nil {
+			var se *jcs.SyntaxError
+			if errors.As(err, &se) {
+				fatal(*quiet, "Invalid JSON", err, 1)
+			}
+			fatal(*quiet, "Failed to canonicalize", err, 1)
+		}
+		encodeElapsed = time.Since(encodeStart)
 	}
-	writeElapsed := time.Since(writeStart)
 
 	// Verbose diagnostics
 	if *verbose {
@@ -177,10 +286,14 @@ func main() {
 			fmt.Fprintf(os.Stderr, "  Input size: %d bytes\n", inputSize)
 		}
 
-		fmt.Fprintf(os.Stderr, "  Buffer capacity: %d bytes\n", bufCap)
-		fmt.Fprintf(os.Stderr, "  Decode time: %v\n", decodeElapsed)
-		fmt.Fprintf(os.Stderr, "  Encode time: %v\n", encodeElapsed)
-		fmt.Fprintf(os.Stderr, "  Write time: %v\n", writeElapsed)
+		if *pretty {
+			fmt.Fprintf(os.Stderr, "  Buffer capacity: %d bytes\n", bufCap)
+			fmt.Fprintf(os.Stderr, "  Read time: %v\n", readElapsed)
+			fmt.Fprintf(os.Stderr, "  Canonicalize time: %v\n", encodeElapsed)
+			fmt.Fprintf(os.Stderr, "  Write time: %v\n", writeElapsed)
+		} else {
+			fmt.Fprintf(os.Stderr, "  Streamed canonicalize time: %v\n", encodeElapsed)
+		}
 		fmt.Fprintf(os.Stderr, "  Total time: %v\n", totalElapsed)
 	}
 
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// TestMain runs main instead of the tests when the test binary is started
+// by runCLI.
+func TestMain(m *testing.M) {
+	if os.Getenv("JCSCLI_TEST_MAIN") == "1" {
+		main()
+	}
+
+	os.Exit(m.Run())
+}
+
+// runCLI runs jcscli with args and stdin, returning its stdout, stderr and
+// exit code.
+func runCLI(t *testing.T, stdin string, args ...string) (string, string, int) {
+	t.Helper()
+
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(os.Environ(), "JCSCLI_TEST_MAIN=1")
+	cmd.Stdin = strings.NewReader(stdin)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+
+	err := cmd.Run()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return stdout.String(), stderr.String(), exitErr.ExitCode()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return stdout.String(), stderr.String(), 0
+}
+
+func TestCLI(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		in         string
+		want       string
+		wantStderr string
+		wantCode   int
+	}{
+		{
+			name: "Array",
+			in:   `[ {"b":1,"a":2}, 1.50 ]`,
+			want: "[{\"a\":2,\"b\":1},1.5]\n",
+		},
+		{
+			// integers beyond ±(2^53 − 1) that a double holds exactly
+			name: "LargeIntegers",
+			in:   `[1e20, 100000000000000000000, 9007199254740992, 999999999999999900000]`,
+			want: "[100000000000000000000,100000000000000000000,9007199254740992,999999999999999900000]\n",
+		},
+		{
+			name: "LargeIntegersObject",
+			args: []string{"-m", "1"},
+			in:   `{"b":100000000000000000000,"a":1E20}`,
+			want: "{\"a\":100000000000000000000,\"b\":100000000000000000000}\n",
+		},
+		{
+			name:       "RoundedInteger",
+			in:         `[9007199254740993]`,
+			wantStderr: "Invalid JSON: jcs: value number out of range (v ± 2^53) at line 1, column 2",
+			wantCode:   1,
+		},
+		{
+			name:     "RoundedIntegerQuiet",
+			args:     []string{"-q"},
+			in:       `{"id":9007199254740993}`,
+			wantCode: 1,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			args := append(tc.args, "-t", t.TempDir())
+			stdout, stderr, code := runCLI(t, tc.in, args...)
+			if code != tc.wantCode || stdout != tc.want || !strings.HasPrefix(stderr, tc.wantStderr) {
+				t.Fatalf("jcscli %v = %q, %q, exit %d; want %q, %q..., exit %d",
+					args, stdout, stderr, code, tc.want, tc.wantStderr, tc.wantCode)
+			}
+		})
+	}
+}
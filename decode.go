@@ -0,0 +1,248 @@
+package jcs
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Unmarshal decodes the canonical JSON text data into the value pointed to
+// by v, refusing any input that is not byte-for-byte canonical.
+//
+// data is first checked with Verify, so the bytes that were verified, for
+// example against a signature, are exactly the bytes that get decoded: a
+// document whose members were reordered, whose numbers were reformatted or
+// that carries duplicate member names is rejected instead of being decoded
+// to the same value.
+//
+// Decoding then follows encoding/json with numbers decoded as json.Number
+// when the target is an interface value, so that v may be a pointer to a
+// map[string]any, []any, json.Number, a struct or any other type accepted
+// by json.Unmarshal. Like encoding/json, member names match struct fields
+// case-insensitively when there is no exact match, but an object with two
+// members matching the same field, such as "ID" and "id", is rejected
+// rather than decoded with the last one winning.
+//
+// Error handling:
+//   - Returns the *SyntaxError reported by Verify for malformed or
+//     non-canonical input; errors.Is matches ErrNotCanonical or the sentinel
+//     describing why the input cannot be canonicalized.
+//   - Returns a *SyntaxError wrapping ErrDuplicateKey if two members of an
+//     object match the same struct field.
+//   - Otherwise returns the error of encoding/json, e.g. a
+//     *json.UnmarshalTypeError when a value does not fit the target type.
+func Unmarshal(data []byte, v any) error {
+	if err := Verify(data); err != nil {
+		return err
+	}
+	if err := checkFieldNames(data, v); err != nil {
+		return err
+	}
+
+	return decodeCanonical(data, v, false)
+}
+
+// Decoder reads and decodes a stream of canonical JSON values, the
+// counterpart of Append for inbound signed messages.
+//
+// Values in the stream may be separated by whitespace, such as the newlines
+// of a JSON Lines file, but each value itself must be canonical as checked
+// by Verify.
+type Decoder struct {
+	dec *json.Decoder
+
+	disallowUnknownFields bool
+}
+
+// NewDecoder returns a new Decoder that reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{dec: json.NewDecoder(r)}
+}
+
+// DisallowUnknownFields causes the Decoder to return an error when the
+// destination is a struct and the input contains object members that do
+// not match any exported field, so that no verified data is silently
+// dropped.
+func (d *Decoder) DisallowUnknownFields() {
+	d.disallowUnknownFields = true
+}
+
+// Decode reads the next JSON value from its input, checks that it is
+// canonical and stores it in the value pointed to by v, as Unmarshal does.
+//
+// It returns io.EOF when the input is exhausted. The Offset of a returned
+// *SyntaxError is relative to the start of the input, while its Line and
+// Column are relative to the start of the offending value.
+func (d *Decoder) Decode(v any) error {
+	var raw json.RawMessage
+	if err := d.dec.Decode(&raw); err != nil {
+		return err
+	}
+
+	err := Verify(raw)
+	if err == nil {
+		err = checkFieldNames(raw, v)
+	}
+	if err != nil {
+		var syntaxErr *SyntaxError
+		if errors.As(err, &syntaxErr) {
+			syntaxErr.Offset += d.dec.InputOffset() - int64(len(raw))
+		}
+		return err
+	}
+
+	return decodeCanonical(raw, v, d.disallowUnknownFields)
+}
+
+// decodeCanonical decodes data, which has already been verified, into v.
+func decodeCanonical(data []byte, v any, disallowUnknownFields bool) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if disallowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+
+	return dec.Decode(v)
+}
+
+// checkFieldNames returns a *SyntaxError wrapping ErrDuplicateKey if two
+// members of an object in the verified text data would be decoded into the
+// same field of a struct reachable from the type of v. encoding/json
+// matches member names to fields case-insensitively and lets the last
+// member win, so canonical input such as {"ID":2,"id":1} would otherwise
+// decode to a value its bytes do not show.
+func checkFieldNames(data []byte, v any) error {
+	t := reflect.TypeOf(v)
+	if t == nil {
+		return nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	c := fieldNameChecker{data: data, dec: dec}
+	return c.value(t)
+}
+
+// fieldNameChecker walks verified JSON text along the Go type it is decoded
+// into, looking for members that match the same struct field.
+type fieldNameChecker struct {
+	data []byte
+	dec  *json.Decoder
+}
+
+var jsonUnmarshalerType = reflect.TypeFor[json.Unmarshaler]()
+
+// value checks the next JSON value of the input, to be decoded into a value
+// of type t. A nil t skips the value.
+func (c *fieldNameChecker) value(t reflect.Type) error {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t != nil && reflect.PointerTo(t).Implements(jsonUnmarshalerType) {
+		// decoded by its UnmarshalJSON method, not by field names
+		t = nil
+	}
+
+	tok, err := c.dec.Token()
+	if err != nil {
+		return err
+	}
+
+	switch tok {
+	case json.Delim('['):
+		var elem reflect.Type
+		if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+			elem = t.Elem()
+		}
+		for c.dec.More() {
+			if err := c.value(elem); err != nil {
+				return err
+			}
+		}
+
+	case json.Delim('{'):
+		if err := c.object(t); err != nil {
+			return err
+		}
+
+	default:
+		return nil
+	}
+
+	// closing delimiter
+	_, err = c.dec.Token()
+	return err
+}
+
+// object checks the members of a JSON object whose opening brace has been
+// read, to be decoded into a value of type t.
+func (c *fieldNameChecker) object(t reflect.Type) error {
+	var fields []field
+	var elem reflect.Type
+	if t != nil {
+		switch t.Kind() {
+		case reflect.Struct:
+			fields = cachedFields(t)
+		case reflect.Map:
+			elem = t.Elem()
+		}
+	}
+
+	var seen map[int]string
+	for c.dec.More() {
+		tok, err := c.dec.Token()
+		if err != nil {
+			return err
+		}
+		name := tok.(string)
+
+		if t != nil && t.Kind() == reflect.Struct {
+			i := matchField(fields, name)
+			if i < 0 {
+				elem = nil
+			} else {
+				if prev, ok := seen[i]; ok {
+					quoted, _ := appendString(nil, name)
+					offset := int(c.dec.InputOffset()) - len(quoted)
+					msg := fmt.Sprintf("member %q matches the same field %s as %q", name, fields[i].name, prev)
+					return newSyntaxError(c.data, offset, ErrDuplicateKey, msg)
+				}
+				if seen == nil {
+					seen = make(map[int]string)
+				}
+				seen[i] = name
+				elem = t.FieldByIndex(fields[i].index).Type
+			}
+		}
+
+		if err := c.value(elem); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// matchField returns the index in fields of the field that encoding/json
+// decodes the member name into, or -1 if there is none: the field with
+// that exact name or else the first field, in declaration order, whose
+// name is equal to it under Unicode case folding.
+func matchField(fields []field, name string) int {
+	match := -1
+	for i := range fields {
+		if fields[i].name == name {
+			return i
+		}
+		if strings.EqualFold(fields[i].name, name) &&
+			(match < 0 || slices.Compare(fields[i].index, fields[match].index) < 0) {
+			match = i
+		}
+	}
+
+	return match
+}
@@ -0,0 +1,169 @@
+package jcs
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+type decodeOrder struct {
+	ID    string          `json:"id"`
+	Items []decodeItem    `json:"items"`
+	Meta  map[string]any  `json:"meta"`
+	Raw   json.RawMessage `json:"raw"`
+}
+
+type decodeItem struct {
+	Price float64 `json:"price"`
+	Qty   int     `json:"qty"`
+}
+
+type decodeAccount struct {
+	ID    int          `json:"id"`
+	Name  string       `json:"name"`
+	Items []decodeItem `json:"items"`
+}
+
+func TestUnmarshal(t *testing.T) {
+	t.Run("Any", func(t *testing.T) {
+		var v any
+		Equals(t, nil, Unmarshal([]byte(`{"a":[1.5,"x",true,null],"b":{}}`), &v))
+		Equals(t, map[string]any{
+			"a": []any{json.Number("1.5"), "x", true, nil},
+			"b": map[string]any{},
+		}, v)
+	})
+
+	t.Run("Number", func(t *testing.T) {
+		var n json.Number
+		Equals(t, nil, Unmarshal([]byte(`1e+21`), &n))
+		Equals(t, json.Number("1e+21"), n)
+	})
+
+	t.Run("Struct", func(t *testing.T) {
+		in := `{"id":"o-1","items":[{"price":9.99,"qty":2}],"meta":{"n":1},"raw":{"k":[1]}}`
+
+		var o decodeOrder
+		Equals(t, nil, Unmarshal([]byte(in), &o))
+		Equals(t, decodeOrder{
+			ID:    "o-1",
+			Items: []decodeItem{{Price: 9.99, Qty: 2}},
+			Meta:  map[string]any{"n": json.Number("1")},
+			Raw:   json.RawMessage(`{"k":[1]}`),
+		}, o)
+
+		// decoding and encoding again yields the verified bytes
+		out, err := Append(nil, o)
+		Equals(t, nil, err)
+		Equals(t, in, string(out))
+	})
+
+	t.Run("NotCanonical", func(t *testing.T) {
+		var v map[string]any
+		err := Unmarshal([]byte(`{"b":1,"a":2}`), &v)
+		Equals(t, true, errors.Is(err, ErrNotCanonical))
+		Equals(t, map[string]any(nil), v)
+
+		var syntaxErr *SyntaxError
+		Equals(t, true, errors.As(err, &syntaxErr))
+		Equals(t, int64(7), syntaxErr.Offset)
+	})
+
+	t.Run("DuplicateKey", func(t *testing.T) {
+		var v decodeItem
+		err := Unmarshal([]byte(`{"price":1,"price":2}`), &v)
+		Equals(t, true, errors.Is(err, ErrDuplicateKey))
+	})
+
+	t.Run("CaseFoldedDuplicate", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			in     string
+			offset int64
+		}{
+			{name: "Name", in: `{"NAME":"a","name":"b"}`, offset: 12},
+			{name: "ID", in: `{"ID":2,"id":1}`, offset: 8},
+			{name: "Nested", in: `{"items":[{"QTY":1,"qty":2}]}`, offset: 19},
+		}
+
+		for _, tc := range tests {
+			t.Run(tc.name, func(t *testing.T) {
+				var v decodeAccount
+				err := Unmarshal([]byte(tc.in), &v)
+				Equals(t, true, errors.Is(err, ErrDuplicateKey))
+				Equals(t, decodeAccount{}, v)
+
+				var syntaxErr *SyntaxError
+				Equals(t, true, errors.As(err, &syntaxErr))
+				Equals(t, tc.offset, syntaxErr.Offset)
+			})
+		}
+	})
+
+	t.Run("CaseInsensitiveMatch", func(t *testing.T) {
+		// a single member still matches like in encoding/json
+		var v decodeAccount
+		Equals(t, nil, Unmarshal([]byte(`{"ID":2,"Name":"a"}`), &v))
+		Equals(t, decodeAccount{ID: 2, Name: "a"}, v)
+
+		var m map[string]any
+		Equals(t, nil, Unmarshal([]byte(`{"ID":2,"id":1}`), &m))
+	})
+
+	t.Run("TypeMismatch", func(t *testing.T) {
+		var v decodeItem
+		var typeErr *json.UnmarshalTypeError
+		Equals(t, true, errors.As(Unmarshal([]byte(`{"qty":"x"}`), &v), &typeErr))
+	})
+}
+
+func TestDecoder(t *testing.T) {
+	in := "{\"price\":1,\"qty\":1}\n{\"price\":2,\"qty\":2}\n{\"qty\":3, \"price\":3}\n"
+	dec := NewDecoder(strings.NewReader(in))
+
+	var item decodeItem
+	Equals(t, nil, dec.Decode(&item))
+	Equals(t, decodeItem{Price: 1, Qty: 1}, item)
+
+	Equals(t, nil, dec.Decode(&item))
+	Equals(t, decodeItem{Price: 2, Qty: 2}, item)
+
+	err := dec.Decode(&item)
+	Equals(t, true, errors.Is(err, ErrNotCanonical))
+
+	var syntaxErr *SyntaxError
+	Equals(t, true, errors.As(err, &syntaxErr))
+	// the third value starts at offset 40, the space is its 10th byte
+	Equals(t, int64(40+9), syntaxErr.Offset)
+
+	dec = NewDecoder(strings.NewReader(`{"price":1,"qty":1}`))
+	Equals(t, nil, dec.Decode(&item))
+	Equals(t, io.EOF, dec.Decode(&item))
+}
+
+func TestDecoderDisallowUnknownFields(t *testing.T) {
+	dec := NewDecoder(strings.NewReader(`{"extra":true,"price":1}`))
+	dec.DisallowUnknownFields()
+
+	var item decodeItem
+	err := dec.Decode(&item)
+	Equals(t, true, err != nil)
+	Equals(t, false, errors.Is(err, ErrNotCanonical))
+}
+
+func TestDecoderCaseFoldedDuplicate(t *testing.T) {
+	dec := NewDecoder(strings.NewReader(`{"id":1} {"ID":2,"id":1}`))
+	dec.DisallowUnknownFields()
+
+	var v decodeAccount
+	Equals(t, nil, dec.Decode(&v))
+
+	err := dec.Decode(&v)
+	Equals(t, true, errors.Is(err, ErrDuplicateKey))
+
+	var syntaxErr *SyntaxError
+	Equals(t, true, errors.As(err, &syntaxErr))
+	Equals(t, int64(17), syntaxErr.Offset)
+}
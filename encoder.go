@@ -0,0 +1,331 @@
+package jcs
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"sync"
+	"unsafe"
+)
+
+// Encoder appends the canonical JSON representation of Go values according
+// to a fixed configuration.
+//
+// An Encoder is built once with NewEncoder and the options a service needs,
+// then reused for every value: besides its configuration it keeps the
+// scratch buffers used to sort object members, so encoding many values with
+// the same Encoder does not allocate them again. The zero options give
+// exactly the behavior of the package-level Append.
+//
+// An Encoder is not safe for concurrent use by multiple goroutines. Use one
+// Encoder per goroutine, or the package-level Append, which draws default
+// encoders from a pool.
+type Encoder struct {
+	// limits bound the resources spent on a single value, zero means
+	// unlimited. See WithMaxDepth and the related options.
+	maxDepth        int
+	maxOutputSize   int
+	maxMembers      int
+	maxStringLength int
+
+	// policies relaxing RFC 8785, see policy.go.
+	nonFiniteAsNull    bool
+	replaceInvalidUTF8 bool
+	dropUnsupported    bool
+
+	// largeNumbersAsStrings writes numbers that are not exact doubles as
+	// strings, see WithLargeNumbersAsStrings.
+	largeNumbersAsStrings bool
+
+	// shortestFloat32 writes float32 values in their shortest float32
+	// form, see WithShortestFloat32.
+	shortestFloat32 bool
+
+	// timeFormat and zeroTimeAsNull select how time.Time values are
+	// written, see WithTimeFormat.
+	timeFormat     TimeFormat
+	zeroTimeAsNull bool
+
+	// durationFormat and bytesFormat select how time.Duration and byte
+	// slices are written, see WithDurationFormat and WithBytesFormat.
+	durationFormat DurationFormat
+	bytesFormat    BytesFormat
+
+	// ctx is the context of the current AppendContext call, checked every
+	// contextCheckInterval values counted by steps.
+	ctx   context.Context
+	steps int
+
+	// depth is the number of arrays and objects being encoded, and
+	// dstStart the length of dst when the current call started.
+	depth    int
+	dstStart int
+
+	// w is the writer of a StreamEncoder, to which the output is written
+	// every chunkSize bytes; flushed counts the bytes written by the
+	// current call.
+	w         io.Writer
+	chunkSize int
+	flushed   int
+
+	// memoryBudget and tempDir configure the external sort of the members
+	// of a top-level object by TransformStream, see WithMemoryBudget.
+	memoryBudget int
+	tempDir      string
+
+	// utf16 holds the UTF-16 code units of the member names of the objects
+	// being encoded, indexed by keys.
+	//
+	// The scratch slices are used as stacks: every object pushes its keys on
+	// top of those of its enclosing objects and truncates them back when it
+	// is done, so one set of buffers serves any nesting depth. Popped
+	// entries are cleared so that e does not retain the encoded values.
+	utf16 []uint16
+	keys  []kv
+
+	// vals holds the member values of the maps being encoded by appendMap,
+	// indexed by kv.idx.
+	vals []reflect.Value
+
+	// refLevel is the number of maps, slices and pointers being encoded,
+	// and refSeen the levels of those among them past
+	// startDetectingCyclesAfter, see enterRef.
+	refLevel uint
+	refSeen  map[ref]uint
+
+	// cycle tracks the ErrCycle error being unwound, see cutCyclePath.
+	cycle *cycleState
+
+	// collect makes containers record the errors of their elements in
+	// violations and go on instead of failing, see Validate.
+	collect    bool
+	violations []Violation
+}
+
+// Option configures an Encoder, see NewEncoder.
+type Option func(*Encoder)
+
+// NewEncoder returns an Encoder configured by opts, which are applied in
+// order so that a later option overrides an earlier one. Without options
+// the Encoder behaves exactly like the package-level Append.
+func NewEncoder(opts ...Option) *Encoder {
+	e := new(Encoder)
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	return e
+}
+
+// Append appends the canonical JSON representation of v to dst, as the
+// package-level Append does but with the configuration of e.
+//
+// Values implementing Marshaler or json.Marshaler, and json.RawMessage, are
+// encoded with their own rules: AppendJCS receives no Encoder, so nested
+// values it encodes with the package-level Append use the defaults. The
+// output of MarshalJSON and json.RawMessage values are canonicalized as by
+// e.Transform, within the limits and context of the call.
+//
+// If an error occurs, dst is returned unchanged.
+func (e *Encoder) Append(dst []byte, v any) ([]byte, error) {
+	return e.encode(nil, dst, v)
+}
+
+// AppendContext is like Append but stops encoding v with the error of ctx
+// once ctx is done. The context is checked before encoding starts and then
+// periodically, so that encoding a very large value can be cancelled.
+func (e *Encoder) AppendContext(ctx context.Context, dst []byte, v any) ([]byte, error) {
+	return e.encode(ctx, dst, v)
+}
+
+// encode implements Append and AppendContext. ctx may be nil.
+func (e *Encoder) encode(ctx context.Context, dst []byte, v any) ([]byte, error) {
+	if err := e.begin(ctx, dst); err != nil {
+		return dst, err
+	}
+	defer func() { e.ctx = nil }()
+
+	dst, err := e.append(dst, v)
+	if e.dropped(err) {
+		dst, err = append(dst, 'n', 'u', 'l', 'l'), nil
+	}
+	if err == nil {
+		err = e.checkOutputSize(dst)
+	}
+	if err != nil {
+		return e.truncate(dst, e.dstStart), err
+	}
+
+	return dst, nil
+}
+
+// Transform appends the canonical form of the JSON text src to dst, as the
+// package-level Transform does but within the limits of e: WithMaxDepth,
+// WithMaxOutputSize, WithMaxMembers and WithMaxStringLength apply to the
+// arrays, objects and strings of src, and fail with a *SyntaxError wrapping
+// the matching error at the position of the offending value. The other
+// options have no effect.
+func (e *Encoder) Transform(dst, src []byte) ([]byte, error) {
+	return e.transform(nil, dst, src)
+}
+
+// TransformContext is like Transform but stops once ctx is done, checking
+// it like AppendContext does. An error of ctx found while parsing src is
+// wrapped in a *SyntaxError at the position reached.
+func (e *Encoder) TransformContext(ctx context.Context, dst, src []byte) ([]byte, error) {
+	return e.transform(ctx, dst, src)
+}
+
+// transform implements Transform and TransformContext. ctx may be nil.
+func (e *Encoder) transform(ctx context.Context, dst, src []byte) ([]byte, error) {
+	if err := e.begin(ctx, dst); err != nil {
+		return dst, err
+	}
+	defer func() { e.ctx = nil }()
+
+	return transformWith(dst, src, e)
+}
+
+// begin starts a call of e appending to dst, checking ctx every
+// contextCheckInterval values if it can be cancelled. It returns the error
+// of ctx if ctx is already done. The caller must clear e.ctx when done.
+func (e *Encoder) begin(ctx context.Context, dst []byte) error {
+	if ctx != nil {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if ctx.Done() == nil {
+			// never cancelled, e.g. context.Background()
+			ctx = nil
+		}
+	}
+
+	e.ctx, e.steps, e.dstStart, e.flushed = ctx, 0, len(dst), 0
+
+	return nil
+}
+
+// encoderPool holds default encoders whose scratch buffers are reused by
+// subsequent calls to the package-level Append.
+var encoderPool = sync.Pool{
+	New: func() any { return NewEncoder() },
+}
+
+// startDetectingCyclesAfter is the nesting level of maps, slices and
+// pointers after which the encoder starts tracking the references it
+// descends into. Acyclic values rarely nest that deep, so they are encoded
+// without the cost of cycle detection, while a cycle is still reported
+// after a bounded amount of recursion. It matches encoding/json.
+const startDetectingCyclesAfter = 1000
+
+// ref identifies the contents of a map, slice or pointer being encoded. A
+// slice is identified by its length as well, since s and s[:1] share their
+// first element but not their encoding.
+type ref struct {
+	ptr unsafe.Pointer
+	len int
+}
+
+// enterRef records that the encoder descends into the map, slice or pointer
+// whose data starts at ptr. It returns ErrCycle if that reference is
+// already being encoded further up, i.e. if the value contains itself.
+//
+// Every call that returns nil must be paired with a call to leaveRef.
+func (e *Encoder) enterRef(ptr unsafe.Pointer, n int) error {
+	if e.refLevel++; e.refLevel <= startDetectingCyclesAfter || ptr == nil {
+		return nil
+	}
+
+	r := ref{ptr, n}
+	if level, ok := e.refSeen[r]; ok {
+		period := e.refLevel - level
+		e.cycle = &cycleState{
+			violation: -1,
+			detected:  e.refLevel,
+			period:    period,
+			level:     e.refLevel,
+			refs:      make([]ref, period),
+			pos:       make([]int, period),
+		}
+		e.cycle.refs[e.refLevel%period] = r
+		e.refLevel--
+		return ErrCycle
+	}
+
+	if e.refSeen == nil {
+		e.refSeen = make(map[ref]uint)
+	}
+	e.refSeen[r] = e.refLevel
+
+	return nil
+}
+
+// leaveRef undoes the matching call to enterRef.
+func (e *Encoder) leaveRef(ptr unsafe.Pointer, n int) {
+	if e.refLevel > startDetectingCyclesAfter {
+		delete(e.refSeen, ref{ptr, n})
+	}
+	if e.cycle != nil {
+		e.cutCyclePath(e.refLevel, ref{ptr, n})
+	}
+
+	if e.refLevel--; e.refLevel == 0 {
+		e.cycle = nil
+	}
+}
+
+// cycleState is the ErrCycle error being unwound.
+type cycleState struct {
+	// err is the error once pathError wrapped it, or violation its index
+	// in Encoder.violations when collecting, -1 until recorded.
+	err       *EncodeError
+	violation int
+
+	// detected is the level at which a reference was found to contain
+	// itself, period the number of levels after which it repeats, and
+	// level the last level the error was unwound through.
+	detected, period, level uint
+
+	// refs and pos hold, for the last period levels unwound through, the
+	// reference at that level and the value of prepended at the time.
+	refs []ref
+	pos  []int
+
+	// prepended is the number of bytes prepended to the path so far, and
+	// kept the length of the path after it was last cut.
+	prepended, kept int
+}
+
+// cutCyclePath is called by leaveRef as the ErrCycle error unwinds through
+// the reference r at level. A cycle is only detected deep into the value,
+// but the path of the error ends where it first closes: wherever the
+// reference of a level repeats one period further down, everything below
+// that repetition is cut off. A map stored in its own member "self" thus
+// fails at /self, and a ring of two nodes at /next/next.
+func (e *Encoder) cutCyclePath(level uint, r ref) {
+	c := e.cycle
+	if level != c.level-1 {
+		// a sibling encoded after the error was recorded by Validate
+		return
+	}
+	c.level = level
+
+	var path *string
+	switch {
+	case c.err != nil:
+		path = &c.err.Path
+	case c.violation >= 0:
+		path = &e.violations[c.violation].Path
+	default:
+		// not yet wrapped, nothing was prepended
+		path = new(string)
+	}
+
+	c.prepended += len(*path) - c.kept
+	i := level % c.period
+	if level+c.period <= c.detected && c.refs[i] == r {
+		*path = (*path)[:c.prepended-c.pos[i]]
+	}
+	c.refs[i], c.pos[i] = r, c.prepended
+	c.kept = len(*path)
+}
@@ -0,0 +1,200 @@
+package jcs
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestEncoderAppend(t *testing.T) {
+	testCases := []struct {
+		name  string
+		value any
+	}{
+		{name: "Scalar", value: 1.5},
+		{name: "NestedMaps", value: map[string]any{
+			"b": map[string]any{"z": 1, "y": map[string]any{"q": true, "p": nil}},
+			"a": []any{map[string]any{"d": "x", "c": "y"}, map[string]string{"2": "", "1": ""}},
+		}},
+		{name: "MapOfMaps", value: map[string]map[string]int{
+			"y": {"b": 2, "a": 1},
+			"x": {"d": 4, "c": 3},
+		}},
+		{name: "Struct", value: struct {
+			B map[string]any `json:"b"`
+			A []int          `json:"a"`
+		}{B: map[string]any{"k2": 2, "k1": 1}, A: []int{1, 2}}},
+	}
+
+	e := NewEncoder()
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			want, err := Append(nil, tc.value)
+			Equals(t, nil, err)
+
+			// the same encoder gives the same output on every reuse
+			for range 3 {
+				got, err := e.Append(nil, tc.value)
+				Equals(t, nil, err)
+				Equals(t, string(want), string(got))
+			}
+
+			Equals(t, 0, len(e.keys))
+			Equals(t, 0, len(e.utf16))
+			Equals(t, 0, len(e.vals))
+		})
+	}
+}
+
+func TestEncoderAppendError(t *testing.T) {
+	e := NewEncoder()
+
+	_, err := e.Append(nil, map[string]any{
+		"a": map[string]any{"b": map[string]float64{"c": math.NaN()}},
+	})
+	Equals(t, true, errors.Is(err, ErrNaN))
+
+	// the scratch stacks are popped on errors too
+	Equals(t, 0, len(e.keys))
+	Equals(t, 0, len(e.utf16))
+	Equals(t, 0, len(e.vals))
+	for _, k := range e.keys[:cap(e.keys)] {
+		Equals(t, kv{}, k)
+	}
+
+	got, err := e.Append(nil, map[string]any{"b": 1, "a": 2})
+	Equals(t, nil, err)
+	Equals(t, `{"a":2,"b":1}`, string(got))
+}
+
+func TestAppendConcurrent(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	sample := randomMap(100, rng)
+
+	want, err := Append(nil, sample)
+	Equals(t, nil, err)
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 50 {
+				got, err := Append(nil, sample)
+				if err != nil || string(got) != string(want) {
+					t.Errorf("concurrent Append mismatch: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func BenchmarkEncoderAppend(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+
+	for _, size := range []int{10, 100, 1000} {
+		sample := map[string]any{"items": []any{randomMap(size, rng), randomMap(size, rng)}}
+		dst := make([]byte, 0, 1024)
+
+		b.Run("Size"+strconv.Itoa(size), func(b *testing.B) {
+			b.ReportAllocs()
+
+			e := NewEncoder()
+			for b.Loop() {
+				if _, err := e.Append(dst[:0], sample); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+type cycleNode struct {
+	Name string     `json:"name"`
+	Next *cycleNode `json:"next"`
+}
+
+func TestEncoderCycle(t *testing.T) {
+	selfMap := map[string]any{"a": 1}
+	selfMap["self"] = selfMap
+
+	selfSlice := []any{1, nil}
+	selfSlice[1] = selfSlice
+
+	ring := &cycleNode{Name: "a", Next: &cycleNode{Name: "b"}}
+	ring.Next.Next = ring
+
+	typedMap := map[string][]any{}
+	typedMap["x"] = []any{typedMap}
+
+	testCases := []struct {
+		name  string
+		value any
+		path  string
+	}{
+		{name: "Map", value: selfMap, path: "/self"},
+		{name: "Slice", value: selfSlice, path: "/1"},
+		{name: "Pointer", value: ring, path: "/next/next"},
+		{name: "Struct", value: cycleNode{Next: ring}, path: "/next/next/next"},
+		{name: "MapOfSlices", value: typedMap, path: "/x/0"},
+		{name: "Nested", value: []any{map[string]any{"loop": selfMap}}, path: "/0/loop/self"},
+		{name: "PointerInMap", value: map[string]any{"ring": ring}, path: "/ring/next/next"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := NewEncoder()
+
+			out, err := e.Append([]byte("prefix"), tc.value)
+			Equals(t, true, errors.Is(err, ErrCycle))
+			Equals(t, "prefix", string(out))
+
+			var encErr *EncodeError
+			Equals(t, true, errors.As(err, &encErr))
+			Equals(t, tc.path, encErr.Path)
+
+			// the encoder is left ready for the next value
+			Equals(t, uint(0), e.refLevel)
+			Equals(t, 0, len(e.refSeen))
+			Equals(t, (*cycleState)(nil), e.cycle)
+		})
+	}
+}
+
+func TestEncoderDeepAcyclic(t *testing.T) {
+	// nesting beyond startDetectingCyclesAfter is not a cycle, even when
+	// the same leaf is shared by several branches.
+	shared := []any{1}
+	var v any = map[string]any{"a": shared, "b": shared}
+	want := `{"a":[1],"b":[1]}`
+	for range startDetectingCyclesAfter + 100 {
+		v = []any{v, shared}
+		want = "[" + want + ",[1]]"
+	}
+
+	got, err := Append(nil, v)
+	Equals(t, nil, err)
+	Equals(t, want, string(got))
+}
+
+func TestValidateCycle(t *testing.T) {
+	selfMap := map[string]any{}
+	selfMap["self"] = selfMap
+
+	ring := &cycleNode{Name: "a", Next: &cycleNode{Name: "b"}}
+	ring.Next.Next = ring
+
+	violations := Validate(map[string]any{"bad": math.NaN(), "loop": selfMap, "ring": ring})
+	Equals(t, 3, len(violations))
+	Equals(t, "/bad", violations[0].Path)
+	Equals(t, ErrCycle, violations[1].Err)
+	Equals(t, "/loop/self", violations[1].Path)
+	Equals(t, ErrCycle, violations[2].Err)
+	Equals(t, "/ring/next/next", violations[2].Path)
+}
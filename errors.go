@@ -1,12 +1,19 @@
 package jcs
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
 
 var (
 	// ErrUnsupportedType is returned when the encoder encounters a value
 	// of an unsupported type. The encoder only supports specific types
-	// like integers, strings, maps, and slices. Custom structs or other
-	// complex types may trigger this error.
+	// like integers, strings, maps, slices and structs. Functions, channels,
+	// complex numbers or other types without a JSON representation trigger
+	// this error.
 	ErrUnsupportedType = errors.New("jcs: value has unsupported type")
 
 	// ErrNaN is returned when the encoder encounters a NaN (Not a Number)
@@ -29,4 +36,219 @@ var (
 	// Numbers larger than ±2^53 cannot be exactly represented, and JCS requires
 	// exact round-trip encoding. This error occurs when such a number is encountered.
 	ErrNumberOOR = errors.New("jcs: value number out of range (v ± 2^53)")
+
+	// ErrInvalidNumber is returned when a textual number, such as a
+	// json.Number, is not a valid JSON number literal as defined by
+	// RFC 8259 section 6.
+	ErrInvalidNumber = errors.New("jcs: invalid number literal")
+
+	// ErrNumberPrecision is returned when a textual number is rounded by
+	// the conversion to an IEEE-754 double, e.g. "0.10000000000000000001",
+	// "1.0000000000000001" or "1e-400". Encoding it would silently change
+	// its value, which JCS does not allow.
+	ErrNumberPrecision = errors.New("jcs: number cannot be represented exactly as IEEE-754 double")
+
+	// ErrDuplicateKey is returned when JSON text contains an object with
+	// two members of the same name. RFC 8785 relies on I-JSON (RFC 7493),
+	// which forbids duplicate names, since their meaning is ambiguous.
+	ErrDuplicateKey = errors.New("jcs: duplicate object member name")
+
+	// ErrNotCanonical is returned by Verify when its input is valid JSON
+	// but not byte-for-byte in the canonical form defined by RFC 8785,
+	// e.g. because of whitespace, unsorted members, a number that is not
+	// in its shortest form or an unnecessary escape sequence.
+	ErrNotCanonical = errors.New("jcs: not in canonical form")
+
+	// ErrNoncharacter is reported by Validate for strings and member names
+	// containing Unicode noncharacters (U+FDD0–U+FDEF and the last two code
+	// points of every plane, such as U+FFFE). I-JSON (RFC 7493) forbids
+	// them, but RFC 8785 can represent them, so Append accepts them.
+	ErrNoncharacter = errors.New("jcs: string contains unicode noncharacter")
+
+	// ErrCycle is returned when a map, slice or pointer contains itself,
+	// directly or through other values, such as a map[string]any stored
+	// in one of its own members. Such a value has no finite JSON
+	// representation; without the check encoding it would recurse until
+	// the goroutine stack overflows.
+	ErrCycle = errors.New("jcs: value contains a reference cycle")
+
+	// ErrMaxDepth is returned by an Encoder configured with WithMaxDepth
+	// when arrays and objects are nested deeper than allowed, which guards
+	// the recursive encoder against hostile, deeply nested input.
+	//
+	// This and the other limit errors are also reported for JSON text, in
+	// a json.RawMessage, the output of MarshalJSON, or the input of
+	// Encoder.Transform and TransformStream, wrapped in a *SyntaxError.
+	ErrMaxDepth = errors.New("jcs: maximum nesting depth exceeded")
+
+	// ErrMaxOutputSize is returned by an Encoder configured with
+	// WithMaxOutputSize when the canonical form of a value grows beyond
+	// the allowed number of bytes.
+	ErrMaxOutputSize = errors.New("jcs: maximum output size exceeded")
+
+	// ErrMaxMembers is returned by an Encoder configured with
+	// WithMaxMembers for a map with more members than allowed, before its
+	// members are sorted.
+	ErrMaxMembers = errors.New("jcs: maximum number of object members exceeded")
+
+	// ErrMaxStringLength is returned by an Encoder configured with
+	// WithMaxStringLength for a string or member name longer than allowed.
+	ErrMaxStringLength = errors.New("jcs: maximum string length exceeded")
+
+	// ErrInvalidToken is returned by a Writer for a token that cannot
+	// appear at the current position, such as a member name outside of an
+	// object, a value where a member name is expected, an EndArray that
+	// closes an object or a second top-level value.
+	ErrInvalidToken = errors.New("jcs: invalid token sequence")
 )
+
+// SyntaxError describes JSON text that is malformed or cannot be
+// canonicalized, such as input passed to Transform. It records where the
+// problem was detected so that it can be reported to the author of the
+// document.
+//
+// When the problem is a canonicalization failure rather than a grammar
+// violation, Err holds the corresponding sentinel (ErrDuplicateKey,
+// ErrInvalidUTF8, ErrNumberOOR, ...) and errors.Is matches it.
+type SyntaxError struct {
+	// Offset is the byte offset in the input at which the error was
+	// detected.
+	Offset int64
+
+	// Line and Column are the 1-based line and byte column of Offset.
+	Line, Column int
+
+	// Err is the sentinel error describing the failure, or nil for plain
+	// syntax errors.
+	Err error
+
+	msg string
+}
+
+// newSyntaxError returns a SyntaxError for the byte at offset in src. The
+// message defaults to the text of err without its package prefix.
+func newSyntaxError(src []byte, offset int, err error, msg string) *SyntaxError {
+	if msg == "" && err != nil {
+		msg = strings.TrimPrefix(err.Error(), "jcs: ")
+	}
+
+	line, column := 1, 1
+	for _, c := range src[:offset] {
+		if c == '\n' {
+			line++
+			column = 1
+			continue
+		}
+		column++
+	}
+
+	return &SyntaxError{
+		Offset: int64(offset),
+		Line:   line,
+		Column: column,
+		Err:    err,
+		msg:    msg,
+	}
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("jcs: %s at line %d, column %d (offset %d)", e.msg, e.Line, e.Column, e.Offset)
+}
+
+func (e *SyntaxError) Unwrap() error {
+	return e.Err
+}
+
+// EncodeError describes a value nested in an array or object that could not
+// be encoded. It records where the value sits in the document, so that a
+// failure deep inside a large value can be traced back to its origin.
+//
+// Err holds the underlying error, usually a sentinel such as ErrNaN or
+// ErrInvalidUTF8, and errors.Is matches it. Errors for a top-level value are
+// returned as-is, since they have no location to report.
+type EncodeError struct {
+	// Path is the RFC 6901 JSON Pointer of the offending value, e.g.
+	// "/orders/12/price". For an invalid member name it points to the
+	// object holding the member.
+	Path string
+
+	// Type is the Go type of the offending value.
+	Type reflect.Type
+
+	// Err is the error returned while encoding the value.
+	Err error
+}
+
+func (e *EncodeError) Error() string {
+	msg := strings.TrimPrefix(e.Err.Error(), "jcs: ")
+	if e.Type == nil {
+		return fmt.Sprintf("jcs: %s at %s", msg, e.Path)
+	}
+	return fmt.Sprintf("jcs: %s at %s (%s)", msg, e.Path, e.Type)
+}
+
+func (e *EncodeError) Unwrap() error {
+	return e.Err
+}
+
+// pointerEscaper escapes a JSON Pointer reference token, RFC 6901 section 3.
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// elemError handles the outcome of encoding the array element at index i,
+// of type t, when it returned err or recorded violations past mark. See
+// pathError.
+func (e *Encoder) elemError(err error, mark, i int, t reflect.Type) error {
+	return e.pathError(err, mark, strconv.Itoa(i), t)
+}
+
+// memberError handles the outcome of encoding the value of the object
+// member name, of type t, when it returned err or recorded violations past
+// mark. See pathError.
+func (e *Encoder) memberError(err error, mark int, name string, t reflect.Type) error {
+	return e.pathError(err, mark, pointerEscaper.Replace(name), t)
+}
+
+// pathError prepends the reference token of a nested value to the path of
+// its error as it unwinds through the enclosing array or object. A bare
+// error becomes an *EncodeError for a value of type t.
+//
+// When e collects violations for Validate, the token is prepended to the
+// violations recorded for the value since mark instead, err is recorded as
+// a new violation and nil is returned so that encoding goes on.
+func (e *Encoder) pathError(err error, mark int, token string, t reflect.Type) error {
+	if e.collect {
+		for i := mark; i < len(e.violations); i++ {
+			e.violations[i].Path = "/" + token + e.violations[i].Path
+		}
+		if err != nil {
+			if err == ErrCycle && e.cycle != nil && e.cycle.violation < 0 {
+				e.cycle.violation = len(e.violations)
+			}
+			e.violations = append(e.violations, Violation{Path: "/" + token, Type: t, Err: err})
+		}
+		return nil
+	}
+
+	if ee, ok := err.(*EncodeError); ok {
+		ee.Path = "/" + token + ee.Path
+		return ee
+	}
+
+	ee := &EncodeError{Path: "/" + token, Type: t, Err: err}
+	if err == ErrCycle && e.cycle != nil && e.cycle.err == nil {
+		e.cycle.err = ee
+	}
+	return ee
+}
+
+// keyError handles err, returned for a member name of the object of type t.
+// It is returned unchanged for the enclosing value to locate, or recorded
+// as a violation of the object itself when e collects violations.
+func (e *Encoder) keyError(err error, t reflect.Type) error {
+	if e.collect {
+		e.violations = append(e.violations, Violation{Type: t, Err: err})
+		return nil
+	}
+
+	return err
+}
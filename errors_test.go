@@ -0,0 +1,94 @@
+package jcs
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+type encodeErrorOrder struct {
+	Items []encodeErrorItem `json:"items"`
+}
+
+type encodeErrorItem struct {
+	Price float64 `json:"price"`
+}
+
+func TestEncodeError(t *testing.T) {
+	testCases := []struct {
+		name     string
+		value    any
+		wantPath string
+		wantType reflect.Type
+		wantErr  error
+	}{
+		{
+			name: "MapOfSlices",
+			value: map[string]any{
+				"orders": []any{1, 2, map[string]any{"price": math.NaN()}},
+			},
+			wantPath: "/orders/2/price",
+			wantType: reflectTypeOf[float64](),
+			wantErr:  ErrNaN,
+		},
+		{
+			name:     "Struct",
+			value:    map[string]encodeErrorOrder{"o": {Items: []encodeErrorItem{{1}, {math.Inf(1)}}}},
+			wantPath: "/o/items/1/price",
+			wantType: reflectTypeOf[float64](),
+			wantErr:  ErrInf,
+		},
+		{
+			name:     "TypedSlice",
+			value:    []string{"ok", string([]byte{0xff})},
+			wantPath: "/1",
+			wantType: reflectTypeOf[string](),
+			wantErr:  ErrInvalidUTF8,
+		},
+		{
+			name:     "Array",
+			value:    [2][]int{{1}, {2, math.MaxInt64}},
+			wantPath: "/1/1",
+			wantType: reflectTypeOf[int](),
+			wantErr:  ErrNumberOOR,
+		},
+		{
+			name:     "EscapedNames",
+			value:    map[string]any{"a/b": map[string]any{"m~n": func() {}}},
+			wantPath: "/a~1b/m~0n",
+			wantType: reflectTypeOf[func()](),
+			wantErr:  ErrUnsupportedType,
+		},
+		{
+			name:     "InvalidMemberName",
+			value:    []any{map[string]int{string([]byte{0xff}): 1}},
+			wantPath: "/0",
+			wantType: reflectTypeOf[map[string]int](),
+			wantErr:  ErrInvalidUTF8,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Append(nil, tc.value)
+			Equals(t, true, errors.Is(err, tc.wantErr))
+
+			var encErr *EncodeError
+			Equals(t, true, errors.As(err, &encErr))
+			Equals(t, tc.wantPath, encErr.Path)
+			Equals(t, tc.wantType, encErr.Type)
+		})
+	}
+}
+
+func TestEncodeErrorTopLevel(t *testing.T) {
+	// a top-level value has no location, its error is returned as-is
+	_, err := Append(nil, math.NaN())
+	Equals(t, ErrNaN, err)
+}
+
+func TestEncodeErrorMessage(t *testing.T) {
+	_, err := Append(nil, map[string]any{"orders": []float64{1, math.NaN()}})
+	Equals(t, "jcs: cannot c14n NaN at /orders/1 (float64)", err.Error())
+}
@@ -0,0 +1,34 @@
+package jcs
+
+import (
+	"math"
+	"strconv"
+)
+
+// WithShortestFloat32 makes the encoder write float32 values with the
+// shortest decimal that converts back to the same float32, such as 0.1 for
+// float32(0.1), laid out with the ECMAScript rules of appendNumber.
+//
+// By default a float32 is widened to float64 first, as RFC 8785 describes
+// every number as a double, and float32(0.1) is written as the exact value
+// of the widened double, 0.10000000149011612. With this option the output
+// is still canonical JSON, but a reader parsing it as a double obtains
+// 0.1, a different value than the float64 widening of the original float32:
+// a verifier re-encoding float32 data must use the same option.
+func WithShortestFloat32() Option {
+	return func(e *Encoder) {
+		e.shortestFloat32 = true
+	}
+}
+
+// appendFloat32 appends v widened to float64, or in its shortest float32
+// form when e is configured with WithShortestFloat32.
+func (e *Encoder) appendFloat32(dst []byte, v float32) ([]byte, error) {
+	f := float64(v)
+	if !e.shortestFloat32 || f == 0 || math.IsNaN(f) || math.IsInf(f, 0) {
+		return e.appendFloat(dst, f)
+	}
+
+	var buf [32]byte
+	return appendExponential(dst, string(strconv.AppendFloat(buf[:0], f, 'e', -1, 32))), nil
+}
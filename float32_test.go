@@ -0,0 +1,74 @@
+package jcs
+
+import (
+	"math"
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+func TestShortestFloat32(t *testing.T) {
+	testCases := []struct {
+		name  string
+		value any
+		want  string
+	}{
+		{name: "Tenth", value: float32(0.1), want: `0.1`},
+		{name: "Negative", value: float32(-273.15), want: `-273.15`},
+		{name: "Integer", value: float32(16777216), want: `16777216`},
+		{name: "Large", value: float32(1.5e10), want: `15000000000`},
+		{name: "Exponent", value: float32(1e21), want: `1e+21`},
+		{name: "Max", value: float32(math.MaxFloat32), want: `3.4028235e+38`},
+		{name: "Small", value: float32(1.25e-6), want: `0.00000125`},
+		{name: "SmallExponent", value: float32(1e-7), want: `1e-7`},
+		{name: "Subnormal", value: float32(math.SmallestNonzeroFloat32), want: `1e-45`},
+		{name: "Zero", value: float32(0), want: `0`},
+		{name: "NegativeZero", value: float32(math.Copysign(0, -1)), want: `0`},
+		{name: "Slice", value: []float32{0.1, 0.2, 0.3}, want: `[0.1,0.2,0.3]`},
+		{name: "Named", value: []celsius{21.7}, want: `[21.7]`},
+		{name: "Map", value: map[string]any{"t": float32(36.6)}, want: `{"t":36.6}`},
+		{name: "Float64Unchanged", value: float64(float32(0.1)), want: `0.10000000149011612`},
+	}
+
+	e := NewEncoder(WithShortestFloat32())
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := e.Append(nil, tc.value)
+			Equals(t, nil, err)
+			Equals(t, tc.want, string(got))
+		})
+	}
+}
+
+type celsius float32
+
+func TestShortestFloat32Default(t *testing.T) {
+	got, err := Append(nil, float32(0.1))
+	Equals(t, nil, err)
+	Equals(t, `0.10000000149011612`, string(got))
+
+	_, err = NewEncoder(WithShortestFloat32()).Append(nil, float32(math.NaN()))
+	Equals(t, ErrNaN, err)
+}
+
+func TestShortestFloat32RoundTrip(t *testing.T) {
+	e := NewEncoder(WithShortestFloat32())
+	rng := rand.New(rand.NewSource(1))
+
+	for range 100000 {
+		v := math.Float32frombits(rng.Uint32())
+		if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+			continue
+		}
+
+		got, err := e.Append(nil, v)
+		Equals(t, nil, err)
+
+		f, err := strconv.ParseFloat(string(got), 32)
+		Equals(t, nil, err)
+		if float32(f) != v {
+			t.Fatalf("%s does not round-trip to %g", got, v)
+		}
+	}
+}
@@ -13,20 +13,52 @@
 //     ±(2^53 − 1) cannot be represented exactly and return ErrNumberOOR.
 //   - Canonical ordering of object keys using UTF‑16 code unit comparison,
 //     ensuring correct handling of non‑BMP characters (surrogate pairs).
-//   - Support for slices of common Go types (ints, uints, floats, strings, bools, any)
-//     and maps with string keys.
+//   - Support for slices and arrays of any Go type, maps with string, integer
+//     or encoding.TextMarshaler keys and pointers, with fast paths for common types (ints, uints, floats,
+//     strings, bools, any).
+//   - Reflection-based encoding of structs honoring `json` tags with the same
+//     rules as encoding/json, and of named types by their underlying kind.
+//   - Support for json.Marshaler, whose output is re-canonicalized, and
+//     encoding.TextMarshaler.
+//   - A Marshaler interface for types that append their own canonical form,
+//     together with the exported primitives AppendString, AppendNumber,
+//     CompareKeys and SortKeys to build it.
+//   - Numbers written exactly like ECMAScript Number.prototype.toString,
+//     with a Schubfach shortest-digits generator; FormatNumber and
+//     CanonicalNumber expose it for float64 values and number literals.
 //   - Rejection of unsupported or non‑representable types with ErrUnsupportedType.
 //
 // The core entry point is Append, which appends the canonical JSON representation
 // of a Go value to a destination byte slice. Helper functions such as appendSlice
 // and appendObject handle composite types. Errors are returned when values cannot
-// be represented according to RFC 8785.
+// be represented according to RFC 8785. An Encoder created with NewEncoder
+// carries its own configuration, such as resource limits for untrusted data,
+// and reusable scratch buffers. AppendContext allows cancelling long encodes.
+// Validate reports every value that prevents a Go value from being encoded.
+// StreamEncoder writes the canonical form to an io.Writer in chunks instead,
+// and Writer builds it from a sequence of tokens.
+//
+// Transform canonicalizes JSON text directly, using a strict tokenizer that
+// rejects duplicate member names, lone surrogates and numbers that cannot be
+// represented exactly, and reports the position of every error.
+// TransformStream does the same from an io.Reader to an io.Writer, one
+// element at a time for a top-level array, and can sort the members of a
+// top-level object larger than memory in runs spilled to a temporary file.
+// Verify and IsCanonical check that JSON text is already byte-for-byte
+// canonical, and Unmarshal and Decoder decode only input that passes Verify.
 //
 // This package is intended for use in contexts where canonical JSON is required
 // for interoperability, compliance, or cryptographic integrity.
 package jcs
 
-import "time"
+import (
+	"context"
+	"encoding"
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"time"
+)
 
 // Append function is part of the jcs package, which implements the JSON
 // Canonicalization Scheme (JCS) as defined in RFC 8785. This function appends
@@ -35,22 +67,54 @@ import "time"
 // appropriate format.
 //
 // Supported types include:
+//   - Marshaler → the output of AppendJCS, checked before any other type
 //   - nil → serialized as "null"
 //   - bool → serialized as "true" or "false"
 //   - string → serialized with proper escaping
 //   - float64 → serialized as a canonical JSON number
+//   - json.Number → parsed and serialized as a canonical JSON number when it
+//     can be represented exactly as an IEEE‑754 double
+//   - *big.Int, *big.Float, *big.Rat → serialized as a canonical JSON number
+//     when exactly representable (integers within ±(2^53 − 1))
+//   - json.RawMessage → parsed, validated and re-serialized in canonical
+//     form (members sorted, numbers normalized, strings re-escaped)
 //   - float32, int, int8, int16, int32, int64, uint, uint8, uint16,
 //     uint32, uint64 → converted to float64 when within IEEE‑754 safe range
 //     (±(2^53 − 1)); otherwise ErrNumberOOR is returned
 //   - slices of common types (ints, uints, floats, strings, bools, any)
 //   - map[string]any → serialized as a JSON object with keys ordered
 //     by UTF‑16 code unit comparison, as required by RFC 8785
+//   - structs → serialized as a JSON object of their exported fields,
+//     honoring `json` tags (name, "-", omitempty, omitzero, string) and
+//     promoting fields of embedded structs like encoding/json does
+//   - named types whose underlying kind is a bool, number or string
+//     (e.g. `type Status string`) → serialized like that kind
+//   - any other slice or array (e.g. []map[string]any, [3]float64,
+//     `type Tags []string`) → serialized as a JSON array
+//   - any other map whose key kind is string (e.g. map[string]string)
+//     → serialized as a JSON object ordered like map[string]any
+//   - maps with integer or encoding.TextMarshaler keys (e.g. map[int]T,
+//     map[netip.Addr]T) → serialized like encoding/json converts their
+//     keys to member names, then ordered like map[string]any
+//   - pointers and interfaces → serialized as the value they point to,
+//     nil → "null"
+//   - json.Marshaler → the output of MarshalJSON, parsed and re-encoded
+//     in canonical form
+//   - encoding.TextMarshaler → the output of MarshalText as a JSON string
 //
 // Errors:
 //   - ErrNumberOOR is returned when an integer cannot be represented
 //     exactly in IEEE‑754 double precision.
+//   - ErrNumberPrecision and ErrInvalidNumber are returned when a
+//     json.Number cannot be represented exactly or is malformed.
 //   - ErrUnsupportedType is returned when v is of a type not supported
-//     by this implementation.
+//     by this implementation, including maps with float, bool or struct
+//     keys.
+//   - ErrDuplicateKey is returned when two keys of a map are converted to
+//     the same member name.
+//   - ErrCycle is returned when a map, slice or pointer contains itself.
+//   - Errors for a value nested in an array or object are wrapped in an
+//     *EncodeError recording its JSON Pointer path and Go type.
 //
 // This function is the core entry point for canonical JSON serialization
 // in the package. It ensures deterministic output suitable for cryptographic
@@ -65,7 +129,37 @@ import "time"
 //	 buf, _ = jcs.Append(buf, response)
 //	 fmt.Println(string(buf))
 //	 Output: {"age":31,"user_id":"c3f65f70-eb2f-4979-ba73-24bcbde9fdd9"}
+//
+// Append uses the default configuration. NewEncoder returns an Encoder whose
+// options change how particular types are encoded.
 func Append(dst []byte, v any) ([]byte, error) {
+	e := encoderPool.Get().(*Encoder)
+	defer encoderPool.Put(e)
+
+	return e.encode(nil, dst, v)
+}
+
+// AppendContext is like Append but stops encoding v with the error of ctx
+// once ctx is done, see Encoder.AppendContext.
+func AppendContext(ctx context.Context, dst []byte, v any) ([]byte, error) {
+	e := encoderPool.Get().(*Encoder)
+	defer encoderPool.Put(e)
+
+	return e.encode(ctx, dst, v)
+}
+
+// append is the implementation of Append for the configuration of e.
+func (e *Encoder) append(dst []byte, v any) ([]byte, error) {
+	if e.ctx != nil || e.maxOutputSize > 0 {
+		if err := e.step(dst); err != nil {
+			return dst, err
+		}
+	}
+
+	if m, ok := v.(Marshaler); ok {
+		return appendMarshalJCS(dst, m)
+	}
+
 	switch v := v.(type) {
 	case nil:
 		return append(dst, 'n', 'u', 'l', 'l'), nil
@@ -79,114 +173,161 @@ func Append(dst []byte, v any) ([]byte, error) {
 		}
 
 	case string:
-		return appendString(dst, v)
+		return e.appendString(dst, v)
 
 	case float64:
-		return appendNumber(dst, v)
+		return e.appendFloat(dst, v)
 
 	case float32:
-		return Append(dst, float64(v))
+		return e.appendFloat32(dst, v)
 
 	case int:
 		if isNumberOOR(v) {
-			return dst, ErrNumberOOR
+			return e.appendLargeInt(dst, int64(v))
 		}
-		return Append(dst, float64(v))
+		return e.append(dst, float64(v))
 
 	case int8:
-		return Append(dst, float64(v))
+		return e.append(dst, float64(v))
 
 	case int16:
-		return Append(dst, float64(v))
+		return e.append(dst, float64(v))
 
 	case int32:
-		return Append(dst, float64(v))
+		return e.append(dst, float64(v))
 
 	case int64:
 		if isNumberOOR(v) {
-			return dst, ErrNumberOOR
+			return e.appendLargeInt(dst, v)
 		}
 
-		return Append(dst, float64(v))
+		return e.append(dst, float64(v))
 
 	case uint:
 		if isNumberOOR(v) {
-			return dst, ErrNumberOOR
+			return e.appendLargeUint(dst, uint64(v))
 		}
 
-		return Append(dst, float64(v))
+		return e.append(dst, float64(v))
 
 	case uint8:
-		return Append(dst, float64(v))
+		return e.append(dst, float64(v))
 
 	case uint16:
-		return Append(dst, float64(v))
+		return e.append(dst, float64(v))
 
 	case uint32:
-		return Append(dst, float64(v))
+		return e.append(dst, float64(v))
 
 	case uint64:
 		if isNumberOOR(v) {
-			return dst, ErrNumberOOR
+			return e.appendLargeUint(dst, v)
 		}
 
-		return Append(dst, float64(v))
+		return e.append(dst, float64(v))
 
 	case []int:
-		return appendSlice(dst, v)
+		return appendSlice(e, dst, v)
 
 	case []int8:
-		return appendSlice(dst, v)
+		return appendSlice(e, dst, v)
 
 	case []int16:
-		return appendSlice(dst, v)
+		return appendSlice(e, dst, v)
 
 	case []int32:
-		return appendSlice(dst, v)
+		return appendSlice(e, dst, v)
 
 	case []int64:
-		return appendSlice(dst, v)
+		return appendSlice(e, dst, v)
 
 	case []uint:
-		return appendSlice(dst, v)
+		return appendSlice(e, dst, v)
 
-	case []uint8:
-		return appendSlice(dst, v)
+	case []byte:
+		return e.appendBytes(dst, v)
 
 	case []uint16:
-		return appendSlice(dst, v)
+		return appendSlice(e, dst, v)
 
 	case []uint32:
-		return appendSlice(dst, v)
+		return appendSlice(e, dst, v)
 
 	case []uint64:
-		return appendSlice(dst, v)
+		return appendSlice(e, dst, v)
 
 	case []any:
-		return appendSlice(dst, v)
+		return appendSlice(e, dst, v)
 
 	case []bool:
-		return appendSlice(dst, v)
+		return appendSlice(e, dst, v)
 
 	case []string:
-		return appendSlice(dst, v)
+		return appendSlice(e, dst, v)
 
 	case []float32:
-		return appendSlice(dst, v)
+		return appendSlice(e, dst, v)
 
 	case []float64:
-		return appendSlice(dst, v)
+		return appendSlice(e, dst, v)
 
 	case time.Time:
-		return appendTime(dst, v), nil
+		return e.appendTime(dst, &v)
+
+	case time.Duration:
+		return e.appendDuration(dst, v)
+
+	case *time.Time:
+		// checked before json.Marshaler, whose output keeps the offset
+		return e.appendTime(dst, v)
+
+	case json.Number:
+		return e.appendNumberLiteral(dst, string(v))
+
+	// math/big types, checked before their MarshalJSON and MarshalText
+	// methods, which would emit inexact numbers and strings.
+	case *big.Int:
+		return e.appendBigInt(dst, v)
+
+	case big.Int:
+		return e.appendBigInt(dst, &v)
+
+	case *big.Float:
+		return e.appendBigFloat(dst, v)
+
+	case big.Float:
+		return e.appendBigFloat(dst, &v)
+
+	case *big.Rat:
+		return e.appendBigRat(dst, v)
+
+	case big.Rat:
+		return e.appendBigRat(dst, &v)
+
+	case json.RawMessage:
+		// pre-encoded JSON, re-canonicalized in place instead of being
+		// treated as a []byte. nil is null, like RawMessage.MarshalJSON.
+		if v == nil {
+			return append(dst, 'n', 'u', 'l', 'l'), nil
+		}
+		return e.appendJSON(dst, v)
 
 	case map[string]any:
 		// Go strings are UTF-8
 		// RFC 8785 requires UTF-16 code unit comparison, which
 		// diffres for non-BMP chars.
 		// (e.g., (U+1D11E) → UTF-16 surrogate pair )
-		return appendObject(dst, v)
+		return e.appendObject(dst, v)
+	}
+
+	// types providing their own JSON or text form, checked after the type
+	// switch so that e.g. time.Time keeps its canonical representation.
+	switch m := v.(type) {
+	case json.Marshaler:
+		return e.appendMarshalJSON(dst, m)
+	case encoding.TextMarshaler:
+		return e.appendMarshalText(dst, m)
 	}
 
-	return dst, ErrUnsupportedType
+	return e.appendReflect(dst, reflect.ValueOf(v))
 }
@@ -1,6 +1,7 @@
 package jcs
 
 import (
+	"errors"
 	"fmt"
 	"math"
 	"math/rand"
@@ -151,7 +152,7 @@ func TestAppend(t *testing.T) {
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
 			out, err := Append(nil, tc.value)
-			Equals(t, tc.wantErr, err)
+			Equals(t, true, errors.Is(err, tc.wantErr))
 			Equals(t, tc.want, string(out))
 		})
 	}
@@ -0,0 +1,103 @@
+package jcs
+
+// contextCheckInterval is the number of values encoded between two checks
+// of the context passed to AppendContext.
+const contextCheckInterval = 1024
+
+// The limits apply to the values encoded by Encoder.Append, including JSON
+// text held in json.RawMessage values or returned by MarshalJSON, and to
+// the JSON text canonicalized by Encoder.Transform and TransformStream. In
+// JSON text, a violation is reported as a *SyntaxError wrapping the error of
+// the limit. The package-level Append and Transform have no limits, except
+// the nesting depth of 10000 of Transform.
+
+// WithMaxDepth limits the nesting of arrays and objects in an encoded value
+// to n levels; a top-level object holding an array is two levels deep.
+// Deeper values fail with ErrMaxDepth. n <= 0 means no limit, which is the
+// default.
+func WithMaxDepth(n int) Option {
+	return func(e *Encoder) {
+		e.maxDepth = max(n, 0)
+	}
+}
+
+// WithMaxOutputSize limits the canonical form of an encoded value to n
+// bytes. Encoding stops with ErrMaxOutputSize soon after the output grows
+// past n, without encoding the rest of the value. n <= 0 means no limit,
+// which is the default.
+func WithMaxOutputSize(n int) Option {
+	return func(e *Encoder) {
+		e.maxOutputSize = max(n, 0)
+	}
+}
+
+// WithMaxMembers limits the number of members of every map encoded as a
+// JSON object to n. Larger maps fail with ErrMaxMembers before their
+// members are sorted. n <= 0 means no limit, which is the default.
+func WithMaxMembers(n int) Option {
+	return func(e *Encoder) {
+		e.maxMembers = max(n, 0)
+	}
+}
+
+// WithMaxStringLength limits strings and member names to n bytes of UTF-8.
+// Longer strings fail with ErrMaxStringLength before they are escaped.
+// n <= 0 means no limit, which is the default.
+func WithMaxStringLength(n int) Option {
+	return func(e *Encoder) {
+		e.maxStringLength = max(n, 0)
+	}
+}
+
+// enter is called when the encoder starts an array or object. It returns
+// ErrMaxDepth if that exceeds the depth limit of e. Every call that returns
+// nil must be paired with a call to leave.
+func (e *Encoder) enter() error {
+	if e.depth++; e.maxDepth > 0 && e.depth > e.maxDepth {
+		e.depth--
+		return ErrMaxDepth
+	}
+
+	return nil
+}
+
+// leave undoes the matching call to enter.
+func (e *Encoder) leave() {
+	e.depth--
+}
+
+// step is called before every value of the current call is encoded, when
+// e has an output size limit or a context to check.
+func (e *Encoder) step(dst []byte) error {
+	if err := e.checkOutputSize(dst); err != nil {
+		return err
+	}
+
+	if e.ctx != nil {
+		if e.steps++; e.steps%contextCheckInterval == 0 {
+			return e.ctx.Err()
+		}
+	}
+
+	return nil
+}
+
+// checkOutputSize returns ErrMaxOutputSize if the output of the current
+// call exceeds the output size limit of e.
+func (e *Encoder) checkOutputSize(dst []byte) error {
+	if e.maxOutputSize > 0 && e.offset(dst)-e.dstStart > e.maxOutputSize {
+		return ErrMaxOutputSize
+	}
+
+	return nil
+}
+
+// checkMembers returns ErrMaxMembers if an object of n members exceeds the
+// member limit of e.
+func (e *Encoder) checkMembers(n int) error {
+	if e.maxMembers > 0 && n > e.maxMembers {
+		return ErrMaxMembers
+	}
+
+	return nil
+}
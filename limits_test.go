@@ -0,0 +1,253 @@
+package jcs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEncoderLimits(t *testing.T) {
+	nested := func(depth int) any {
+		var v any = "leaf"
+		for i := range depth {
+			if i%2 == 0 {
+				v = []any{v}
+			} else {
+				v = map[string]any{"k": v}
+			}
+		}
+		return v
+	}
+
+	bigMap := map[string]int{}
+	for i := range 10 {
+		bigMap[strings.Repeat("k", i+1)] = i
+	}
+
+	testCases := []struct {
+		name    string
+		opts    []Option
+		value   any
+		wantErr error
+	}{
+		{name: "DepthWithin", opts: []Option{WithMaxDepth(4)}, value: nested(4)},
+		{name: "DepthExceeded", opts: []Option{WithMaxDepth(4)}, value: nested(5), wantErr: ErrMaxDepth},
+		{name: "DepthStruct", opts: []Option{WithMaxDepth(1)}, value: []struct{}{{}}, wantErr: ErrMaxDepth},
+		{name: "DepthTypedMap", opts: []Option{WithMaxDepth(1)}, value: map[string][]int{"a": {1}}, wantErr: ErrMaxDepth},
+		{name: "DepthUnlimited", opts: []Option{WithMaxDepth(0)}, value: nested(200)},
+
+		{name: "OutputWithin", opts: []Option{WithMaxOutputSize(9)}, value: []int{1, 2, 3, 4}},
+		{name: "OutputExceeded", opts: []Option{WithMaxOutputSize(8)}, value: []int{1, 2, 3, 4}, wantErr: ErrMaxOutputSize},
+		{name: "OutputScalar", opts: []Option{WithMaxOutputSize(4)}, value: "abcd", wantErr: ErrMaxOutputSize},
+		{name: "OutputNested", opts: []Option{WithMaxOutputSize(100)}, value: map[string]any{"a": []string{strings.Repeat("x", 60), strings.Repeat("y", 60)}}, wantErr: ErrMaxOutputSize},
+
+		{name: "MembersWithin", opts: []Option{WithMaxMembers(10)}, value: bigMap},
+		{name: "MembersExceeded", opts: []Option{WithMaxMembers(9)}, value: bigMap, wantErr: ErrMaxMembers},
+		{name: "MembersAny", opts: []Option{WithMaxMembers(1)}, value: []any{map[string]any{"a": 1, "b": 2}}, wantErr: ErrMaxMembers},
+
+		{name: "StringWithin", opts: []Option{WithMaxStringLength(3)}, value: []string{"abc", "é"}},
+		{name: "StringExceeded", opts: []Option{WithMaxStringLength(3)}, value: []string{"abc", "abcd"}, wantErr: ErrMaxStringLength},
+		{name: "StringMultiByte", opts: []Option{WithMaxStringLength(3)}, value: "éé", wantErr: ErrMaxStringLength},
+		{name: "MemberName", opts: []Option{WithMaxStringLength(3)}, value: map[string]any{"abcd": 1}, wantErr: ErrMaxStringLength},
+		{name: "StructString", opts: []Option{WithMaxStringLength(3)}, value: struct{ S string }{"abcd"}, wantErr: ErrMaxStringLength},
+		{name: "MarshalTextString", opts: []Option{WithMaxStringLength(5)}, value: marshalText("ab"), wantErr: ErrMaxStringLength},
+		{name: "DurationString", opts: []Option{WithMaxStringLength(3), WithDurationFormat(DurationString)}, value: 90 * time.Second, wantErr: ErrMaxStringLength},
+
+		// json.RawMessage and MarshalJSON output count from the depth at
+		// which they are encoded
+		{name: "RawDepthWithin", opts: []Option{WithMaxDepth(3)}, value: []any{json.RawMessage(`[{}]`)}},
+		{name: "RawDepthExceeded", opts: []Option{WithMaxDepth(2)}, value: []any{json.RawMessage(`[{}]`)}, wantErr: ErrMaxDepth},
+		{name: "RawMembers", opts: []Option{WithMaxMembers(1)}, value: json.RawMessage(`{"a":1,"b":2}`), wantErr: ErrMaxMembers},
+		{name: "RawString", opts: []Option{WithMaxStringLength(3)}, value: json.RawMessage(`["\u00e9\u00e9"]`), wantErr: ErrMaxStringLength},
+		{name: "RawOutput", opts: []Option{WithMaxOutputSize(8)}, value: json.RawMessage(`[1, 2, 3, 4]`), wantErr: ErrMaxOutputSize},
+		{name: "MarshalJSONMembers", opts: []Option{WithMaxMembers(1)}, value: marshalMoney{Amount: "1.50", Currency: "EUR"}, wantErr: ErrMaxMembers},
+		{name: "MarshalJSONString", opts: []Option{WithMaxStringLength(2)}, value: marshalMoney{Amount: "1", Currency: "EUR"}, wantErr: ErrMaxStringLength},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := NewEncoder(tc.opts...)
+
+			out, err := e.Append([]byte("prefix"), tc.value)
+			Equals(t, true, errors.Is(err, tc.wantErr))
+			if err != nil {
+				Equals(t, "prefix", string(out))
+			}
+			Equals(t, 0, e.depth)
+
+			// without limits the value is valid
+			want, err := Append([]byte("prefix"), tc.value)
+			Equals(t, nil, err)
+			if tc.wantErr == nil {
+				Equals(t, string(want), string(out))
+			}
+		})
+	}
+}
+
+func TestEncoderLimitPath(t *testing.T) {
+	e := NewEncoder(WithMaxDepth(2))
+
+	_, err := e.Append(nil, map[string]any{"a": []any{1}, "b": []any{[]any{2}}})
+
+	var encErr *EncodeError
+	Equals(t, true, errors.As(err, &encErr))
+	Equals(t, "/b/0", encErr.Path)
+	Equals(t, ErrMaxDepth, encErr.Err)
+}
+
+func TestAppendContext(t *testing.T) {
+	v := make([]any, 10*contextCheckInterval)
+	for i := range v {
+		v[i] = i
+	}
+
+	t.Run("Background", func(t *testing.T) {
+		want, _ := Append(nil, v)
+		got, err := AppendContext(context.Background(), nil, v)
+		Equals(t, nil, err)
+		Equals(t, string(want), string(got))
+	})
+
+	t.Run("AlreadyCancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		out, err := AppendContext(ctx, []byte("prefix"), 1)
+		Equals(t, context.Canceled, err)
+		Equals(t, "prefix", string(out))
+	})
+
+	t.Run("CancelledWhileEncoding", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+
+		// cancel from within the value, after encoding has started
+		v := append([]any{cancelOnMarshal(cancel)}, v...)
+
+		out, err := NewEncoder().AppendContext(ctx, nil, v)
+		Equals(t, true, errors.Is(err, context.Canceled))
+		Equals(t, 0, len(out))
+
+		var encErr *EncodeError
+		Equals(t, true, errors.As(err, &encErr))
+		// encoding stopped at the first check after the cancellation
+		i, err := strconv.Atoi(strings.TrimPrefix(encErr.Path, "/"))
+		Equals(t, nil, err)
+		Equals(t, true, i < contextCheckInterval)
+	})
+}
+
+// cancelOnMarshal cancels a context when it is encoded.
+type cancelOnMarshal context.CancelFunc
+
+func (c cancelOnMarshal) AppendJCS(dst []byte) ([]byte, error) {
+	c()
+	return append(dst, 'n', 'u', 'l', 'l'), nil
+}
+
+func TestEncoderTransformLimits(t *testing.T) {
+	testCases := []struct {
+		name    string
+		opts    []Option
+		in      string
+		wantErr error
+		offset  int64
+	}{
+		{name: "DepthWithin", opts: []Option{WithMaxDepth(3)}, in: `[{"a":[]}]`},
+		{name: "DepthExceeded", opts: []Option{WithMaxDepth(2)}, in: `[{"a":[]}]`, wantErr: ErrMaxDepth, offset: 6},
+		{name: "Members", opts: []Option{WithMaxMembers(2)}, in: `[{"a":1,"b":2}, {"c":1,"b":2,"a":3}]`, wantErr: ErrMaxMembers, offset: 29},
+		{name: "MembersWithin", opts: []Option{WithMaxMembers(2)}, in: `{"a":{"b":1,"c":2},"d":3}`},
+		{name: "String", opts: []Option{WithMaxStringLength(3)}, in: `["abc", "abcd"]`, wantErr: ErrMaxStringLength, offset: 8},
+		{name: "StringEscapes", opts: []Option{WithMaxStringLength(3)}, in: `"\u00e9\u00e9"`, wantErr: ErrMaxStringLength, offset: 0},
+		{name: "StringEscapesWithin", opts: []Option{WithMaxStringLength(3)}, in: `"\u00e9a"`},
+		{name: "MemberName", opts: []Option{WithMaxStringLength(3)}, in: `{"abcd":1}`, wantErr: ErrMaxStringLength, offset: 1},
+		{name: "Output", opts: []Option{WithMaxOutputSize(8)}, in: `[1, 2, 3, 4]`, wantErr: ErrMaxOutputSize, offset: 12},
+		{name: "OutputWithin", opts: []Option{WithMaxOutputSize(9)}, in: `[1, 2, 3, 4]`},
+		{name: "ObjectMembers", opts: []Option{WithMaxMembers(2)}, in: `{"c":1,"b":2,"a":3}`, wantErr: ErrMaxMembers, offset: 13},
+		{name: "ObjectOutput", opts: []Option{WithMaxOutputSize(12)}, in: `{"a":1, "b":2}`, wantErr: ErrMaxOutputSize, offset: 14},
+		{name: "ObjectOutputWithin", opts: []Option{WithMaxOutputSize(13)}, in: `{"a":1, "b":2}`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			out, err := NewEncoder(tc.opts...).Transform([]byte("prefix"), []byte(tc.in))
+			Equals(t, true, errors.Is(err, tc.wantErr))
+
+			want, _ := Transform([]byte("prefix"), []byte(tc.in))
+			var se *SyntaxError
+			if tc.wantErr == nil {
+				Equals(t, string(want), string(out))
+			} else {
+				Equals(t, "prefix", string(out))
+				Equals(t, true, errors.As(err, &se))
+				Equals(t, tc.offset, se.Offset)
+			}
+
+			// TransformStream applies the same limits, whether a top-level
+			// object is read whole or sorted externally
+			for _, budget := range []int{0, 1} {
+				var buf bytes.Buffer
+				opts := append(tc.opts, WithMemoryBudget(budget), WithTempDir(t.TempDir()))
+				err := TransformStream(&buf, strings.NewReader(tc.in), opts...)
+				Equals(t, true, errors.Is(err, tc.wantErr))
+				if tc.wantErr == nil {
+					Equals(t, string(want[len("prefix"):]), buf.String())
+					continue
+				}
+				Equals(t, true, errors.As(err, &se))
+				Equals(t, tc.offset, se.Offset)
+			}
+		})
+	}
+}
+
+func TestEncoderTransformContext(t *testing.T) {
+	in := "[" + strings.Repeat("1,", 10*contextCheckInterval) + "1]"
+
+	want, err := Transform(nil, []byte(in))
+	Equals(t, nil, err)
+	got, err := NewEncoder().TransformContext(context.Background(), nil, []byte(in))
+	Equals(t, nil, err)
+	Equals(t, string(want), string(got))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = NewEncoder().TransformContext(ctx, nil, []byte(in))
+	Equals(t, context.Canceled, err)
+
+	// cancelled after the first check, while parsing
+	out, err := NewEncoder().TransformContext(&countdownContext{Context: context.Background(), n: 1}, []byte("prefix"), []byte(in))
+	Equals(t, true, errors.Is(err, context.Canceled))
+	Equals(t, "prefix", string(out))
+
+	var se *SyntaxError
+	Equals(t, true, errors.As(err, &se))
+	Equals(t, true, se.Offset < int64(len(in)))
+
+	// the context of AppendContext reaches json.RawMessage values
+	_, err = NewEncoder().AppendContext(&countdownContext{Context: context.Background(), n: 1}, nil, json.RawMessage(in))
+	Equals(t, true, errors.Is(err, context.Canceled))
+}
+
+// countdownContext is a context that can be cancelled, and is once Err has
+// been called n times.
+type countdownContext struct {
+	context.Context
+	n int
+}
+
+func (c *countdownContext) Done() <-chan struct{} {
+	return make(chan struct{})
+}
+
+func (c *countdownContext) Err() error {
+	if c.n--; c.n < 0 {
+		return context.Canceled
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package jcs
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Marshaler is the interface implemented by types that can append their own
+// canonical JSON representation.
+//
+// Append checks for Marshaler before anything else, so implementing it
+// bypasses both the built-in encoding of the type and json.Marshaler, and
+// avoids the parse and re-encode round trip that MarshalJSON requires.
+//
+// AppendJCS must append exactly one canonical JSON value to dst and return
+// the extended slice. Its output is trusted and copied as-is, so
+// implementations should build it from the primitives of this package:
+// Append for nested values, AppendString for strings and member names,
+// AppendNumber for numbers and SortKeys or CompareKeys for member ordering.
+//
+// Example:
+//
+//	func (p Point) AppendJCS(dst []byte) ([]byte, error) {
+//		// "x" < "y" in UTF-16 code unit order
+//		dst = append(dst, `{"x":`...)
+//		dst, err := jcs.AppendNumber(dst, p.X)
+//		if err != nil {
+//			return dst, err
+//		}
+//		dst = append(dst, `,"y":`...)
+//		dst, err = jcs.AppendNumber(dst, p.Y)
+//		if err != nil {
+//			return dst, err
+//		}
+//		return append(dst, '}'), nil
+//	}
+type Marshaler interface {
+	AppendJCS(dst []byte) ([]byte, error)
+}
+
+// appendMarshalJCS appends the output of m.AppendJCS to dst. A nil pointer
+// implementing Marshaler is encoded as null without calling AppendJCS, and
+// any partial output is discarded when AppendJCS fails.
+func appendMarshalJCS(dst []byte, m Marshaler) ([]byte, error) {
+	if isNilPointer(m) {
+		return append(dst, 'n', 'u', 'l', 'l'), nil
+	}
+
+	dstLen := len(dst)
+	dst, err := m.AppendJCS(dst)
+	if err != nil {
+		return dst[:dstLen], err
+	}
+
+	return dst, nil
+}
+
+// appendMarshalJSON appends the canonical form of the JSON produced by
+// m.MarshalJSON to dst.
+//
+// The output of MarshalJSON is not trusted to be canonical: third-party
+// marshalers commonly emit whitespace, unsorted members or non-canonical
+// numbers. It is therefore parsed and re-encoded with appendJSON, so the
+// result follows RFC 8785 regardless of how the marshaler formats it.
+//
+// A nil pointer implementing json.Marshaler is encoded as null without
+// calling MarshalJSON, like encoding/json does.
+func (e *Encoder) appendMarshalJSON(dst []byte, m json.Marshaler) ([]byte, error) {
+	if isNilPointer(m) {
+		return append(dst, 'n', 'u', 'l', 'l'), nil
+	}
+
+	b, err := m.MarshalJSON()
+	if err != nil {
+		return dst, fmt.Errorf("jcs: calling MarshalJSON for type %T: %w", m, err)
+	}
+
+	return e.appendJSON(dst, b)
+}
+
+// appendMarshalText appends the result of m.MarshalText to dst as a JSON
+// string, escaped and validated like any other string of e.
+//
+// A nil pointer implementing encoding.TextMarshaler is encoded as null
+// without calling MarshalText, like encoding/json does.
+func (e *Encoder) appendMarshalText(dst []byte, m encoding.TextMarshaler) ([]byte, error) {
+	if isNilPointer(m) {
+		return append(dst, 'n', 'u', 'l', 'l'), nil
+	}
+
+	b, err := m.MarshalText()
+	if err != nil {
+		return dst, fmt.Errorf("jcs: calling MarshalText for type %T: %w", m, err)
+	}
+
+	return e.appendString(dst, string(b))
+}
+
+// appendJSON parses the JSON text data and appends its canonical form to dst
+// like Transform, within the limits and context of e. Arrays and objects of
+// data count towards the depth limit from the depth at which data is
+// encoded.
+//
+// Error handling:
+//   - Returns a *SyntaxError if data is not valid JSON or cannot be
+//     canonicalized, e.g. because of duplicate member names or numbers that
+//     are not exactly representable as IEEE‑754 doubles, or if it exceeds
+//     a limit of e.
+func (e *Encoder) appendJSON(dst []byte, data []byte) ([]byte, error) {
+	return transformWith(dst, data, e)
+}
+
+// isNilPointer reports whether v holds a nil pointer.
+func isNilPointer(v any) bool {
+	rv := reflect.ValueOf(v)
+	return rv.Kind() == reflect.Pointer && rv.IsNil()
+}
+
+var (
+	marshalerType     = reflect.TypeFor[Marshaler]()
+	jsonMarshalerType = reflect.TypeFor[json.Marshaler]()
+)
+
+// implementsMarshaler reports whether t implements Marshaler,
+// json.Marshaler or encoding.TextMarshaler.
+func implementsMarshaler(t reflect.Type) bool {
+	return t.Implements(marshalerType) ||
+		t.Implements(jsonMarshalerType) ||
+		t.Implements(textMarshalerType)
+}
+
+// addrMode tells appendValue how to encode an addressable value of a type.
+type addrMode uint8
+
+const (
+	// addrCopy encodes a copy of the value with append.
+	addrCopy addrMode = iota
+	// addrMarshal calls the pointer-receiver marshaler of the value.
+	addrMarshal
+	// addrReflect encodes the struct or array in place with appendReflect,
+	// keeping its fields and elements addressable.
+	addrReflect
+)
+
+// addrModeCache maps a reflect.Type to its addrMode.
+var addrModeCache sync.Map
+
+// typeAddrMode returns the addrMode of type t. Types that Append handles
+// specially, such as time.Time and big.Int, or that implement a marshaler
+// with a value receiver, are encoded from a copy.
+func typeAddrMode(t reflect.Type) addrMode {
+	if m, ok := addrModeCache.Load(t); ok {
+		return m.(addrMode)
+	}
+
+	m := addrCopy
+	switch {
+	case t.Kind() == reflect.Pointer || t.Kind() == reflect.Interface || implementsMarshaler(t):
+	case implementsMarshaler(reflect.PointerTo(t)):
+		m = addrMarshal
+	case t == timeType || t == bigIntType || t == bigFloatType || t == bigRatType:
+	case t.Kind() == reflect.Struct || t.Kind() == reflect.Array:
+		m = addrReflect
+	}
+
+	addrModeCache.Store(t, m)
+	return m
+}
+
+var (
+	timeType     = reflect.TypeFor[time.Time]()
+	bigIntType   = reflect.TypeFor[big.Int]()
+	bigFloatType = reflect.TypeFor[big.Float]()
+	bigRatType   = reflect.TypeFor[big.Rat]()
+)
+
+// appendValue appends the canonical form of v to dst like e.append. As in
+// encoding/json, marshalers with pointer receivers are called when v is
+// addressable, such as a struct field reached through a pointer or a slice
+// element.
+func (e *Encoder) appendValue(dst []byte, v reflect.Value) ([]byte, error) {
+	if v.CanAddr() {
+		switch typeAddrMode(v.Type()) {
+		case addrMarshal:
+			return e.append(dst, v.Addr().Interface())
+		case addrReflect:
+			if e.ctx != nil || e.maxOutputSize > 0 {
+				if err := e.step(dst); err != nil {
+					return dst, err
+				}
+			}
+			return e.appendReflect(dst, v)
+		}
+	}
+	return e.append(dst, v.Interface())
+}
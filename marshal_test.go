@@ -0,0 +1,246 @@
+package jcs
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/netip"
+	"testing"
+)
+
+var errMarshal = errors.New("marshal failed")
+
+type marshalMoney struct {
+	Amount   string
+	Currency string
+}
+
+func (m marshalMoney) MarshalJSON() ([]byte, error) {
+	// deliberately non-canonical: whitespace, unsorted members, 1.50
+	return []byte(`{ "currency" : "` + m.Currency + `", "amount" : ` + m.Amount + ` }`), nil
+}
+
+type marshalPtr struct{}
+
+func (*marshalPtr) MarshalJSON() ([]byte, error) {
+	return []byte(`"ptr"`), nil
+}
+
+type marshalFailing struct{}
+
+func (marshalFailing) MarshalJSON() ([]byte, error) {
+	return nil, errMarshal
+}
+
+type marshalInvalid struct{}
+
+func (marshalInvalid) MarshalJSON() ([]byte, error) {
+	return []byte(`{"a":`), nil
+}
+
+type marshalText string
+
+func (m marshalText) MarshalText() ([]byte, error) {
+	return []byte("text:" + m), nil
+}
+
+type marshalTextFailing struct{}
+
+func (marshalTextFailing) MarshalText() ([]byte, error) {
+	return nil, errMarshal
+}
+
+func TestAppendMarshaler(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   any
+		want    string
+		wantErr error
+	}{
+		{name: "MarshalJSONRecanonicalized", value: marshalMoney{Amount: "1.50", Currency: "EUR"}, want: `{"amount":1.5,"currency":"EUR"}`},
+		{name: "MarshalJSONInStruct", value: struct {
+			Price marshalMoney `json:"price"`
+		}{marshalMoney{Amount: "1e3", Currency: "USD"}}, want: `{"price":{"amount":1000,"currency":"USD"}}`},
+		{name: "MarshalJSONPointerReceiver", value: &marshalPtr{}, want: `"ptr"`},
+		{name: "MarshalJSONNilPointer", value: (*marshalPtr)(nil), want: `null`},
+		{name: "MarshalText", value: marshalText("a\"b"), want: `"text:a\"b"`},
+		{name: "MarshalTextMapValue", value: map[string]marshalText{"k": "v"}, want: `{"k":"text:v"}`},
+		{name: "NetipAddr", value: netip.MustParseAddr("2001:db8::1"), want: `"2001:db8::1"`},
+		{name: "ErrMarshalJSON", value: marshalFailing{}, wantErr: errMarshal},
+		{name: "ErrMarshalText", value: marshalTextFailing{}, wantErr: errMarshal},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			out, err := Append(nil, tc.value)
+			Equals(t, true, errors.Is(err, tc.wantErr))
+			Equals(t, tc.want, string(out))
+		})
+	}
+}
+
+type marshalPtrText struct{ V int }
+
+func (*marshalPtrText) MarshalText() ([]byte, error) {
+	return []byte("ptr-text"), nil
+}
+
+type marshalPtrJCS struct{ V int }
+
+func (*marshalPtrJCS) AppendJCS(dst []byte) ([]byte, error) {
+	return append(dst, `"ptr-jcs"`...), nil
+}
+
+type marshalPtrHolder struct {
+	JCS  marshalPtrJCS
+	JSON marshalPtr
+	Text marshalPtrText
+}
+
+func TestAppendPointerReceiverMarshaler(t *testing.T) {
+	tests := []struct {
+		name  string
+		value any
+		want  string
+	}{
+		{name: "FieldsBehindPointer", value: &marshalPtrHolder{}, want: `{"JCS":"ptr-jcs","JSON":"ptr","Text":"ptr-text"}`},
+		{name: "FieldsOfValueNotAddressable", value: marshalPtrHolder{}, want: `{"JCS":{"V":0},"JSON":{},"Text":{"V":0}}`},
+		{name: "SliceElements", value: []marshalPtrText{{1}, {2}}, want: `["ptr-text","ptr-text"]`},
+		{name: "SliceOfStructs", value: []marshalPtrHolder{{}}, want: `[{"JCS":"ptr-jcs","JSON":"ptr","Text":"ptr-text"}]`},
+		{name: "ArrayBehindPointer", value: &[1]marshalPtrJCS{}, want: `["ptr-jcs"]`},
+		{name: "ArrayOfValueNotAddressable", value: [1]marshalPtrJCS{}, want: `[{"V":0}]`},
+		{name: "PointerToValue", value: &marshalPtrText{}, want: `"ptr-text"`},
+		{name: "StringTagged", value: &struct {
+			T marshalPtrText `json:",string"`
+		}{}, want: `{"T":"ptr-text"}`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			out, err := Append(nil, tc.value)
+			Equals(t, nil, err)
+			Equals(t, tc.want, string(out))
+		})
+	}
+}
+
+func TestAppendMarshalerInvalidJSON(t *testing.T) {
+	out, err := Append(nil, marshalInvalid{})
+	Equals(t, true, err != nil)
+	Equals(t, "", string(out))
+}
+
+type marshalPoint struct {
+	X, Y float64
+}
+
+func (p marshalPoint) AppendJCS(dst []byte) ([]byte, error) {
+	dst = append(dst, `{"x":`...)
+	dst, err := AppendNumber(dst, p.X)
+	if err != nil {
+		return dst, err
+	}
+
+	dst = append(dst, `,"y":`...)
+	dst, err = AppendNumber(dst, p.Y)
+	if err != nil {
+		return dst, err
+	}
+
+	return append(dst, '}'), nil
+}
+
+// marshalLabels implements both Marshaler and json.Marshaler, Marshaler
+// must win.
+type marshalLabels map[string]string
+
+func (l marshalLabels) AppendJCS(dst []byte) ([]byte, error) {
+	keys := make([]string, 0, len(l))
+	for k := range l {
+		keys = append(keys, k)
+	}
+	SortKeys(keys)
+
+	dst = append(dst, '{')
+	for i, k := range keys {
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+
+		var err error
+		if dst, err = AppendString(dst, k); err != nil {
+			return dst, err
+		}
+		dst = append(dst, ':')
+		if dst, err = AppendString(dst, l[k]); err != nil {
+			return dst, err
+		}
+	}
+
+	return append(dst, '}'), nil
+}
+
+func (marshalLabels) MarshalJSON() ([]byte, error) {
+	return nil, errMarshal
+}
+
+func TestAppendJCSMarshaler(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   any
+		want    string
+		wantErr error
+	}{
+		{name: "Point", value: marshalPoint{X: 1.5, Y: -0.0}, want: `{"x":1.5,"y":0}`},
+		{name: "PointInSlice", value: []marshalPoint{{X: 1}}, want: `[{"x":1,"y":0}]`},
+		{name: "PointerToPoint", value: &marshalPoint{Y: 2}, want: `{"x":0,"y":2}`},
+		{name: "NilPointer", value: (*marshalPoint)(nil), want: `null`},
+		{name: "PreferredOverMarshalJSON", value: marshalLabels{"ﬁ": "1", "😀": "2", "a": "3"}, want: `{"a":"3","😀":"2","ﬁ":"1"}`},
+		{name: "ErrNaNDiscardsOutput", value: marshalPoint{X: 1, Y: math.NaN()}, wantErr: ErrNaN},
+		{name: "ErrInvalidUTF8", value: marshalLabels{"k": string([]byte{0xff})}, wantErr: ErrInvalidUTF8},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			out, err := Append(nil, tc.value)
+			Equals(t, tc.wantErr, err)
+			Equals(t, tc.want, string(out))
+		})
+	}
+}
+
+func TestAppendRawMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   any
+		want    string
+		wantErr error
+	}{
+		{name: "Recanonicalized", value: json.RawMessage(" { \"b\" : [ 1.0 , \"\\u0041\" ], \"a\" : 1E2 } "), want: `{"a":100,"b":[1,"A"]}`},
+		{name: "Scalar", value: json.RawMessage(`"\u00e9\/"`), want: `"é/"`},
+		{name: "Nil", value: json.RawMessage(nil), want: `null`},
+		{name: "InStruct", value: struct {
+			Payload json.RawMessage `json:"payload"`
+			Kind    string          `json:"kind"`
+		}{json.RawMessage(`{"z":true,"y":null}`), "event"}, want: `{"kind":"event","payload":{"y":null,"z":true}}`},
+		{name: "PointerInMap", value: map[string]*json.RawMessage{"doc": ptr(json.RawMessage(`[3, 2 ,1]`))}, want: `{"doc":[3,2,1]}`},
+		{name: "ErrNumberPrecision", value: json.RawMessage(`{"n":0.10000000000000000001}`), wantErr: ErrNumberPrecision},
+		{name: "ErrDuplicateKey", value: json.RawMessage(`{"a":1,"a":2}`), wantErr: ErrDuplicateKey},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			out, err := Append(nil, tc.value)
+			Equals(t, true, errors.Is(err, tc.wantErr))
+			Equals(t, tc.want, string(out))
+		})
+	}
+
+	for _, invalid := range []string{``, `{"a":}`, `[1,2`, `1 2`, `{"a":1}x`} {
+		t.Run("Invalid"+invalid, func(t *testing.T) {
+			out, err := Append(nil, json.RawMessage(invalid))
+			Equals(t, true, err != nil)
+			Equals(t, "", string(out))
+		})
+	}
+}
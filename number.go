@@ -1,8 +1,11 @@
 package jcs
 
 import (
+	"errors"
 	"math"
+	"math/big"
 	"strconv"
+	"strings"
 )
 
 // MaxSafeNumber defines the largest integer that can be represented exactly
@@ -18,45 +21,6 @@ import (
 // MaxSafeNumber is therefore set to 2^53‑1 (9007199254740991).
 const MaxSafeNumber = 1<<53 - 1
 
-// numberNormalizer normalizes the exponent of a float64 value `v` in the JSON output
-// to adhere strictly to the RFC 8785 canonical JSON format.
-//
-// This function ensures that the floating-point value is serialized in scientific notation,
-// following these rules from RFC 8785:
-//   - The exponent must always be expressed with a sign (`+` or `-`), even for positive exponents.
-//   - The exponent cannot have leading zeros (except for `+00` or `-00`).
-//   - For numbers like 0.00000123 or 1230000000, the exponent part must be adjusted and normalized.
-//   - Example: A value like 1230000000 must be represented as 1.23e9 (with explicit "e+9" as the exponent).
-func numberNormalizer(dst []byte, v float64) []byte {
-	dst = strconv.AppendFloat(dst, v, 'e', -1, 64)
-
-	for i := len(dst) - 1; i >= 0; i-- {
-		if dst[i] == 'e' {
-			// get position '+'
-			j := i + 1
-
-			// remove '+'
-			if dst[j] == '+' {
-				copy(dst[j:], dst[j+1:])
-				dst = dst[:len(dst)-1]
-			}
-
-			// remove leading zero in exponent
-			if j+1 < len(dst) && dst[j] == '-' && dst[j+1] == '0' {
-				copy(dst[j+1:], dst[j+2:])
-				dst = dst[:len(dst)-1]
-			} else if dst[j] == '0' && j+1 < len(dst) {
-				copy(dst[j:], dst[j+1:])
-				dst = dst[:len(dst)-1]
-			}
-
-			return dst
-		}
-	}
-
-	return dst
-}
-
 // isNumberOOR checks whether an integer value lies outside the IEEE‑754 binary64
 // "safe integer" range defined by RFC 8785 (JSON Canonicalization Scheme).
 // RFC 8785 requires that all JSON numbers be representable exactly in
@@ -80,12 +44,59 @@ func isNumberOOR[T int | int64 | uint | uint64](v T) bool {
 	return false
 }
 
+// AppendNumber appends v to dst as a canonical JSON number, formatted with
+// the ECMAScript rules required by RFC 8785. It returns ErrNaN or ErrInf for
+// values that have no JSON representation, in which case dst is returned
+// unchanged.
+//
+// AppendNumber is intended for implementations of Marshaler. Integers
+// should be checked against MaxSafeNumber before being converted to float64,
+// as Append does.
+func AppendNumber(dst []byte, v float64) ([]byte, error) {
+	return appendNumber(dst, v)
+}
+
+// FormatNumber returns v as a canonical JSON number, i.e. the output of the
+// ECMAScript Number.prototype.toString method required by RFC 8785, such
+// as "1.5", "1e+21" or "1e-7". It returns ErrNaN or ErrInf for values that
+// have no JSON representation.
+func FormatNumber(v float64) (string, error) {
+	var buf [32]byte
+	b, err := appendNumber(buf[:0], v)
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
+
+// CanonicalNumber returns the canonical form of the JSON number literal s,
+// e.g. "1.5" for "1.50" or "15E-1", as Transform writes it.
+//
+// Error handling:
+//   - Returns ErrInvalidNumber if s is not a valid JSON number literal.
+//   - Returns ErrNumberOOR if s is an integer that a double would round,
+//     e.g. "9007199254740993", or its magnitude overflows a double.
+//   - Returns ErrNumberPrecision if s is any other literal that a double
+//     would round, e.g. "1.0000000000000001".
+//
+// CanonicalNumber accepts every output of FormatNumber and returns it
+// unchanged.
+func CanonicalNumber(s string) (string, error) {
+	var buf [32]byte
+	b, err := appendNumberLiteral(buf[:0], s)
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
+
 // appendNumber appends the canonical JSON representation of a numeric value to dst.
 //
 // This function implements the numeric serialization rules required by RFC 8785
-// (JSON Canonicalization Scheme). All numeric values are converted to float64
-// internally and then rendered as JSON numbers in their shortest decimal form
-// that guarantees round‑trip exactness.
+// (JSON Canonicalization Scheme): the output is exactly that of the
+// ECMAScript Number.prototype.toString method.
 //
 // Canonicalization rules enforced:
 //
@@ -97,30 +108,25 @@ func isNumberOOR[T int | int64 | uint | uint64](v T) bool {
 //     NaN, +Inf, and -Inf are explicitly disallowed by RFC 8785. If encountered,
 //     the function returns ErrNaN or ErrInf respectively.
 //
-//   - Safe integer range:
-//     Integers must be restricted to the IEEE‑754 double‑precision safe range
-//     [-(2^53-1), +(2^53-1)]. Values outside this range cannot be represented
-//     exactly as float64 and will trigger ErrNumberOOR.
+//   - Integers:
+//     Integral values up to ±2^53 are written directly as decimal integers,
+//     without going through the digit generation.
 //
 //   - Shortest decimal representation:
-//     For finite values within the safe range, strconv.AppendFloat is used with
-//     mode 'f' and precision -1 to produce the shortest correct decimal string.
-//     For very large or very small magnitudes, float64Normalizer is used to
-//     ensure canonical exponent formatting (removing '+' signs and leading zeros).
+//     Other values are converted by shortestDecimal to the shortest digits
+//     that round-trip, closest to v, and laid out by appendDecimal: plain
+//     notation for magnitudes in [1e-6, 1e21), exponent notation with an
+//     explicit sign otherwise, e.g. 1e+21 and 1e-7.
 //
 // Error handling:
 //   - Returns `ErrNaN` if v is NaN.
 //   - Returns `ErrInf` if v is +Inf or -Inf.
-//   - Returns `ErrNumberOOR` if an integer exceeds ±(2^53-1).
 //   - Otherwise, returns the updated dst slice containing the canonical JSON
 //     number.
 //
 // The resulting output is guaranteed to be a valid, canonical JSON number according to RFC 8785.
 func appendNumber(dst []byte, v float64) ([]byte, error) {
 	if v == 0 {
-		if math.Signbit(v) {
-			return append(dst, '0'), nil
-		}
 		return append(dst, '0'), nil
 	}
 	if math.IsNaN(v) {
@@ -130,19 +136,195 @@ func appendNumber(dst []byte, v float64) ([]byte, error) {
 		return dst, ErrInf
 	}
 
-	abs := math.Abs(v)
-	if abs < 1e+21 && abs >= 1e-6 {
-		dst = strconv.AppendFloat(dst, v, 'f', -1, 64)
-		return dst, nil
+	// integer fast path, exact up to 2^53
+	if v >= -(1<<53) && v <= 1<<53 {
+		if i := int64(v); float64(i) == v {
+			return strconv.AppendInt(dst, i, 10), nil
+		}
+	}
+
+	if v < 0 {
+		dst = append(dst, '-')
+		v = -v
+	}
+
+	digits, exp := shortestDecimal(v)
+
+	// at most 17 digits
+	var buf [20]byte
+	i := len(buf)
+	for ; digits > 0; digits /= 10 {
+		i--
+		buf[i] = byte('0' + digits%10)
+	}
+
+	k := len(buf) - i
+	return appendDecimal(dst, string(buf[i:]), exp+k), nil
+}
+
+// appendNumberLiteral appends the canonical JSON representation of the JSON
+// number literal s, such as a json.Number produced by a json.Decoder with
+// UseNumber, to dst.
+//
+// The literal is parsed as an IEEE‑754 double and re-emitted with
+// appendNumber, so "1.50", "15e-1" and "1.5" all produce "1.5". Unlike a
+// plain conversion, the literal must not carry more precision than the
+// parsed double, otherwise digits would be lost silently; see
+// isExactLiteral. Integer literals follow the same rule: "9007199254740992"
+// and "100000000000000000000" are exact doubles, "9007199254740993" is not.
+//
+// Error handling:
+//   - Returns ErrInvalidNumber if s is not a valid JSON number literal.
+//   - Returns ErrNumberOOR if s is an integer that a double would round,
+//     e.g. "9007199254740993", or if its magnitude overflows a double.
+//   - Returns ErrNumberPrecision if s is any other literal a double would
+//     round, e.g. "0.10000000000000000001" or "1.0000000000000001", or
+//     underflows.
+func appendNumberLiteral(dst []byte, s string) ([]byte, error) {
+	integer, ok := scanNumberLiteral(s)
+	if !ok {
+		return dst, ErrInvalidNumber
+	}
+
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		if errors.Is(err, strconv.ErrRange) {
+			return dst, ErrNumberOOR
+		}
+		return dst, ErrInvalidNumber
+	}
+
+	if !isExactLiteral(s, v) {
+		if integer {
+			return dst, ErrNumberOOR
+		}
+		return dst, ErrNumberPrecision
+	}
+
+	return appendNumber(dst, v)
+}
+
+// scanNumberLiteral reports whether s matches the JSON number grammar
+//
+//	-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?
+//
+// and whether it is an integer literal, i.e. has neither a fraction nor an
+// exponent.
+func scanNumberLiteral(s string) (integer, ok bool) {
+	i := 0
+	if i < len(s) && s[i] == '-' {
+		i++
+	}
+
+	switch {
+	case i < len(s) && s[i] == '0':
+		i++
+	case i < len(s) && s[i] >= '1' && s[i] <= '9':
+		i = skipDigits(s, i+1)
+	default:
+		return false, false
+	}
+	integer = true
+
+	if i < len(s) && s[i] == '.' {
+		j := skipDigits(s, i+1)
+		if j == i+1 {
+			return false, false
+		}
+		i, integer = j, false
+	}
+
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		i++
+		if i < len(s) && (s[i] == '+' || s[i] == '-') {
+			i++
+		}
+		j := skipDigits(s, i)
+		if j == i {
+			return false, false
+		}
+		i, integer = j, false
+	}
+
+	return integer, i == len(s)
+}
+
+// skipDigits returns the index of the first non-digit byte of s at or after i.
+func skipDigits(s string, i int) int {
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return i
+}
+
+// maxExactDigits is the largest number of significant digits of the exact
+// decimal value of a double, that of the largest subnormal.
+const maxExactDigits = 767
+
+// isExactLiteral reports whether the valid JSON number literal s, parsed
+// into the double v, can be converted without silently losing digits: s
+// must denote either the shortest decimal that identifies v, which is what
+// appendNumber writes, or the exact binary value of v. Any other literal is
+// rounded, however few digits it has: "9007199254740993", "0.30000000000000001"
+// and "1.0000000000000001" all identify a double, but not their own value.
+func isExactLiteral(s string, v float64) bool {
+	digits, exp, ok := decimalOf(s)
+	if !ok {
+		return false
+	}
+
+	if v == 0 {
+		// "0", "-0.0e7", ... are exact, anything else underflowed
+		return digits == ""
+	}
+
+	shortest, shortestExp := shortestDecimal(math.Abs(v))
+	var buf [20]byte
+	if exp == shortestExp && digits == string(strconv.AppendUint(buf[:0], shortest, 10)) {
+		return true
+	}
+
+	if len(digits) > maxExactDigits {
+		return false
+	}
+
+	// |s| = digits × 10^exp
+	x, _ := new(big.Int).SetString(digits, 10)
+	pow := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(max(exp, -exp))), nil)
+	r := new(big.Rat)
+	if exp < 0 {
+		r.SetFrac(x, pow)
+	} else {
+		r.SetInt(x.Mul(x, pow))
+	}
+
+	return r.Cmp(new(big.Rat).SetFloat64(math.Abs(v))) == 0
+}
+
+// decimalOf decomposes the JSON number literal s into its significant
+// digits, without leading or trailing zeros, and the exponent exp such that
+// |s| = digits × 10^exp. The sign is ignored. For zero, digits is empty.
+// It reports false if the exponent does not fit in an int.
+func decimalOf(s string) (digits string, exp int, ok bool) {
+	s = strings.TrimPrefix(s, "-")
+
+	mantissa, e, hasExp := strings.Cut(s, "e")
+	if !hasExp {
+		mantissa, e, hasExp = strings.Cut(s, "E")
+	}
+	if hasExp {
+		var err error
+		if exp, err = strconv.Atoi(e); err != nil {
+			return "", 0, false
+		}
 	}
 
-	// Both Ryu and Grisu3 are specialized algorithms for converting floating‑point numbers to their shortest correct decimal string representation.
-	// Ryu is newer, provably correct, and consistently fast; Grisu3 is older, very fast in common cases but sometimes falls back to slower routines.
-	// So this is not fastest possible, but quite solid, this dependent on strconv.AppendFloat most of the runtime cost is inside this Go's generic
-	// float-to-string conversion doing heavy math.
-	//
-	// also exponent cleanup overhead, because its adds a small post-processing loop to strip '+' and leading zeros ('0'). It's cheap but still extra
-	// works compared to algorithms that generate the exact format directly (like Ryu).
-	// Specialized algorithms (Ryu, Grisu3) can cut float‑to‑string conversion time further, but they’re complex to implement and maintain.
-	return numberNormalizer(dst, v), nil
+	intPart, frac, _ := strings.Cut(mantissa, ".")
+	exp -= len(frac)
+
+	digits = strings.TrimLeft(intPart+frac, "0")
+	trimmed := strings.TrimRight(digits, "0")
+	exp += len(digits) - len(trimmed)
+
+	return trimmed, exp, true
 }
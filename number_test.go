@@ -1,8 +1,12 @@
 package jcs
 
 import (
+	"encoding/json"
+	"errors"
 	"math"
+	"math/rand/v2"
 	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -33,8 +37,8 @@ func TestAppendNumber(t *testing.T) {
 		{name: "MinNegativeNumber", value: -math.SmallestNonzeroFloat64, want: "-5e-324"},
 
 		// Max positive/negative finite
-		{name: "MaxPosNumber", value: math.MaxFloat64, want: "1.7976931348623157e308"},
-		{name: "MaxNegNumber", value: -math.MaxFloat64, want: "-1.7976931348623157e308"},
+		{name: "MaxPosNumber", value: math.MaxFloat64, want: "1.7976931348623157e+308"},
+		{name: "MaxNegNumber", value: -math.MaxFloat64, want: "-1.7976931348623157e+308"},
 
 		// Max safe integers
 		{name: "MaxPosInt", value: float64(9007199254740992), want: "9007199254740992"},
@@ -44,15 +48,25 @@ func TestAppendNumber(t *testing.T) {
 		{name: "TwoPow68", value: float64(295147905179352830000), want: "295147905179352830000"},
 
 		// Edge rounding and scientific notation
-		{name: "1e+21", value: 1e+21, want: "1e21"},
-		{name: "1e+23", value: 1e+23, want: "1e23"},
+		{name: "1e+21", value: 1e+21, want: "1e+21"},
+		{name: "1e+23", value: 1e+23, want: "1e+23"},
 		{name: "0.000001", value: 0.000001, want: "0.000001"},
 		{name: "9.999999999999997e-7", value: 9.999999999999997e-7, want: "9.999999999999997e-7"},
-		{name: "9.999999999999997e+22", value: 9.999999999999997e+22, want: "9.999999999999997e22"},
-		{name: "1.0000000000000001e+23", value: 1.0000000000000001e+23, want: "1.0000000000000001e23"},
+		{name: "9.999999999999997e+22", value: 9.999999999999997e+22, want: "9.999999999999997e+22"},
+		{name: "1.0000000000000001e+23", value: 1.0000000000000001e+23, want: "1.0000000000000001e+23"},
 		{name: "999999999999999700000", value: 999999999999999700000.0, want: "999999999999999700000"},
 		{name: "999999999999999900000", value: 999999999999999900000.0, want: "999999999999999900000"},
 
+		// Integers and exponent forms
+		{name: "One", value: 1, want: "1"},
+		{name: "NegativeInteger", value: -42, want: "-42"},
+		{name: "TwoPow53", value: 1 << 53, want: "9007199254740992"},
+		{name: "TwoPow53Plus2", value: 1<<53 + 2, want: "9007199254740994"},
+		{name: "1e+20", value: 1e20, want: "100000000000000000000"},
+		{name: "1e-7", value: 1e-7, want: "1e-7"},
+		{name: "1.5e+300", value: -1.5e300, want: "-1.5e+300"},
+		{name: "1.23e-18", value: 123e-20, want: "1.23e-18"},
+
 		// Rounding cluster
 		{name: "333333333.3333332", value: 333333333.3333332, want: "333333333.3333332"},
 		{name: "333333333.3333333", value: 333333333.3333333, want: "333333333.3333333"},
@@ -70,6 +84,180 @@ func TestAppendNumber(t *testing.T) {
 	}
 }
 
+func TestAppendNumberLiteral(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr error
+	}{
+		{name: "Zero", value: "0", want: "0"},
+		{name: "MinusZero", value: "-0", want: "0"},
+		{name: "MinusZeroFraction", value: "-0.000e10", want: "0"},
+		{name: "Integer", value: "42", want: "42"},
+		{name: "TrailingZeros", value: "1.50", want: "1.5"},
+		{name: "Exponent", value: "15E-1", want: "1.5"},
+		{name: "ExponentPlus", value: "1e+3", want: "1000"},
+		{name: "ShortestDouble", value: "0.1", want: "0.1"},
+		{name: "LongButExact", value: "0.30000000000000004", want: "0.30000000000000004"},
+		{name: "MaxSafeInteger", value: "9007199254740991", want: "9007199254740991"},
+		{name: "MinSafeInteger", value: "-9007199254740991", want: "-9007199254740991"},
+		{name: "LargeExponent", value: "1e21", want: "1e+21"},
+		{name: "MaxDouble", value: "1.7976931348623157e308", want: "1.7976931348623157e+308"},
+		{name: "MinSubnormal", value: "5e-324", want: "5e-324"},
+		{name: "LargeExactFraction", value: "295147905179352830000.0", want: "295147905179352830000"},
+		{name: "ExactBinaryValue", value: "0.1000000000000000055511151231257827021181583404541015625", want: "0.1"},
+		{name: "SeventeenDigitsExponent", value: "1.0000000000000001E23", want: "1.0000000000000001e+23"},
+		{name: "SubnormalShortest", value: "1e-320", want: "1e-320"},
+
+		{name: "ExactInteger", value: "9007199254740992", want: "9007199254740992"},
+		{name: "ExactLargeInteger", value: "-18446744073709551616", want: "-18446744073709552000"},
+		{name: "ShortestInteger", value: "100000000000000000000", want: "100000000000000000000"},
+		{name: "ShortestLargeInteger", value: "999999999999999900000", want: "999999999999999900000"},
+		{name: "ShortestIntegerExponent", value: "1000000000000000000000", want: "1e+21"},
+
+		{name: "ErrUnsafeInteger", value: "9007199254740993", wantErr: ErrNumberOOR},
+		{name: "ErrLargeInteger", value: "-18446744073709551617", wantErr: ErrNumberOOR},
+		{name: "ErrHugeInteger", value: "1" + strings.Repeat("0", 400), wantErr: ErrNumberOOR},
+		{name: "ErrOverflow", value: "1e400", wantErr: ErrNumberOOR},
+		{name: "ErrPrecision", value: "0.10000000000000000001", wantErr: ErrNumberPrecision},
+		{name: "ErrPrecisionLargeFraction", value: "9007199254740993.0", wantErr: ErrNumberPrecision},
+		{name: "ErrPrecisionHalfFraction", value: "9007199254740993.5", wantErr: ErrNumberPrecision},
+		{name: "ErrPrecisionSeventeenDigits", value: "1.0000000000000001", wantErr: ErrNumberPrecision},
+		{name: "ErrPrecisionNotShortest", value: "0.30000000000000001", wantErr: ErrNumberPrecision},
+		{name: "ErrPrecisionRFCSample", value: "333333333.33333329", wantErr: ErrNumberPrecision},
+		{name: "ErrUnderflow", value: "1e-400", wantErr: ErrNumberPrecision},
+		{name: "ErrRoundedSubnormal", value: "3e-324", wantErr: ErrNumberPrecision},
+
+		{name: "ErrEmpty", value: "", wantErr: ErrInvalidNumber},
+		{name: "ErrLeadingZero", value: "01", wantErr: ErrInvalidNumber},
+		{name: "ErrLeadingPlus", value: "+1", wantErr: ErrInvalidNumber},
+		{name: "ErrTrailingDot", value: "1.", wantErr: ErrInvalidNumber},
+		{name: "ErrLeadingDot", value: ".5", wantErr: ErrInvalidNumber},
+		{name: "ErrEmptyExponent", value: "1e", wantErr: ErrInvalidNumber},
+		{name: "ErrHex", value: "0x10", wantErr: ErrInvalidNumber},
+		{name: "ErrNaN", value: "NaN", wantErr: ErrInvalidNumber},
+		{name: "ErrSpace", value: " 1", wantErr: ErrInvalidNumber},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			out, err := appendNumberLiteral(nil, tc.value)
+			Equals(t, tc.wantErr, err)
+			Equals(t, tc.want, string(out))
+		})
+	}
+}
+
+func TestFormatNumber(t *testing.T) {
+	tests := []struct {
+		value   float64
+		want    string
+		wantErr error
+	}{
+		{value: 1.5, want: "1.5"},
+		{value: 1e21, want: "1e+21"},
+		{value: 1e-7, want: "1e-7"},
+		{value: -0.0, want: "0"},
+		{value: math.Inf(-1), wantErr: ErrInf},
+	}
+
+	for _, tc := range tests {
+		got, err := FormatNumber(tc.value)
+		Equals(t, tc.wantErr, err)
+		Equals(t, tc.want, got)
+	}
+}
+
+func TestCanonicalNumber(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr error
+	}{
+		{value: "1.50", want: "1.5"},
+		{value: "1E21", want: "1e+21"},
+		{value: "-0.0000001", want: "-1e-7"},
+		{value: "100e-2", want: "1"},
+		{value: "1e+30", want: "1e+30"},
+		{value: "100000000000000000000", want: "100000000000000000000"},
+		{value: "9007199254740992", want: "9007199254740992"},
+		{value: "9007199254740993", wantErr: ErrNumberOOR},
+		{value: "1.", wantErr: ErrInvalidNumber},
+	}
+
+	for _, tc := range tests {
+		got, err := CanonicalNumber(tc.value)
+		Equals(t, tc.wantErr, err)
+		Equals(t, tc.want, got)
+	}
+}
+
+func TestCanonicalNumberFormatNumber(t *testing.T) {
+	// CanonicalNumber returns every output of FormatNumber unchanged
+	values := []float64{1e20, 1e21, 1 << 53, 0x1p64, 999999999999999900000, 295147905179352830000, 1.5, 1e-7, 5e-324, math.MaxFloat64}
+
+	r := rand.New(rand.NewPCG(8785, 5))
+	for range 1 << 14 {
+		values = append(values, math.Float64frombits(r.Uint64()))
+	}
+
+	for _, v := range values {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			continue
+		}
+
+		s, err := FormatNumber(v)
+		Equals(t, nil, err)
+		got, err := CanonicalNumber(s)
+		if err != nil || got != s {
+			t.Fatalf("CanonicalNumber(%s) = %s, %v", s, got, err)
+		}
+	}
+}
+
+func TestAppendJSONNumber(t *testing.T) {
+	dec := json.NewDecoder(strings.NewReader(`{"price":1.50,"id":12345678901234567890}`))
+	dec.UseNumber()
+
+	var v map[string]any
+	Equals(t, nil, dec.Decode(&v))
+
+	_, err := Append(nil, v)
+	Equals(t, true, errors.Is(err, ErrNumberOOR))
+
+	delete(v, "id")
+	out, err := Append(nil, v)
+	Equals(t, nil, err)
+	Equals(t, `{"price":1.5}`, string(out))
+}
+
+func TestAppendJSONNumberLargeInteger(t *testing.T) {
+	// integers beyond ±(2^53 − 1) that a double holds exactly, such as the
+	// output of Append(1e20), are accepted
+	for _, v := range []float64{1 << 53, 1e20, 999999999999999900000, -0x1p64} {
+		out, err := Append(nil, v)
+		Equals(t, nil, err)
+
+		got, err := Append(nil, json.Number(out))
+		Equals(t, nil, err)
+		Equals(t, string(out), string(got))
+	}
+
+	_, err := Append(nil, json.Number("9007199254740993"))
+	Equals(t, true, errors.Is(err, ErrNumberOOR))
+}
+
+func TestAppendJSONNumberPrecision(t *testing.T) {
+	// literals that identify a double but are not its value
+	for _, s := range []string{"9007199254740993.0", "9007199254740993.5", "1.0000000000000001", "0.30000000000000001"} {
+		t.Run(s, func(t *testing.T) {
+			_, err := Append(nil, json.Number(s))
+			Equals(t, true, errors.Is(err, ErrNumberPrecision))
+		})
+	}
+}
+
 func BenchmarkAppendNumber(b *testing.B) {
 	samples := []float64{
 		0.0,
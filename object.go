@@ -1,33 +1,81 @@
 package jcs
 
-import "sort"
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"unicode/utf8"
+)
 
 // appendObject serializes a map[string]any (JSON object) into the destination byte slice `dst`.
 // The function sorts the keys lexicographically, processes UTF-16 encoding for key/value pairs,
 // and ensures the JSON object is serialized in canonical form as per RFC 8785.
-func appendObject(dst []byte, obj map[string]any) ([]byte, error) {
-	dstLen := len(dst)
+//
+// The keys and their UTF-16 code units are pushed onto the scratch stacks of
+// e and popped when the object is done, so nested objects and subsequent
+// calls reuse the same memory.
+//
+// An error returned for a member value is an *EncodeError whose Path starts
+// with the member name. Invalid UTF-8 in a member name is reported as-is,
+// for the enclosing value to locate, and so is ErrCycle if obj is nested in
+// one of its own members.
+func (e *Encoder) appendObject(dst []byte, obj map[string]any) ([]byte, error) {
+	if e.replaceInvalidUTF8 {
+		// repaired names can no longer be looked up in obj
+		return e.appendMap(dst, reflect.ValueOf(obj))
+	}
+
+	if err := e.enter(); err != nil {
+		return dst, err
+	}
+	defer e.leave()
+
+	dstLen := e.offset(dst)
 	dst = append(dst, '{')
 	if len(obj) == 0 {
 		return append(dst, '}'), nil
 	}
 
+	if err := e.checkMembers(len(obj)); err != nil {
+		return e.truncate(dst, dstLen), err
+	}
+
+	ptr := reflect.ValueOf(obj).UnsafePointer()
+	if err := e.enterRef(ptr, 0); err != nil {
+		return e.truncate(dst, dstLen), err
+	}
+	defer e.leaveRef(ptr, 0)
+
+	base := len(e.keys)
+	defer e.popKeys(base)
+
 	// shared UTF-16 buffer for all keys
 	// heuristic: ASCII keys dominate - ~1 code unit per byte
-	utf16buf := make([]uint16, 0, len(obj)*8)
+	utf16Base := len(e.utf16)
+	e.utf16 = slices.Grow(e.utf16, len(obj)*8)
+	e.keys = slices.Grow(e.keys, len(obj))
 
-	keys := make([]kv, 0, len(obj))
 	for k := range obj {
-		start := len(utf16buf)
+		start := len(e.utf16)
 		var n int
 		var err error
 
-		utf16buf, n, err = appendUTF16(utf16buf, k)
+		e.utf16, n, err = appendUTF16(e.utf16, k)
+		if err == nil {
+			err = e.checkString(k)
+		}
 		if err != nil {
-			return dst[:dstLen], err
+			e.utf16 = e.utf16[:start]
+			if err = e.keyError(err, reflect.TypeOf(obj)); err != nil {
+				e.utf16 = e.utf16[:utf16Base]
+				return e.truncate(dst, dstLen), err
+			}
+			continue
 		}
 
-		keys = append(keys, kv{
+		e.keys = append(e.keys, kv{
 			raw:   k,
 			len:   n,
 			start: start,
@@ -35,40 +83,272 @@ func appendObject(dst []byte, obj map[string]any) ([]byte, error) {
 
 	}
 
-	sort.Slice(keys, func(i, j int) bool {
-		a := utf16buf[keys[i].start : keys[i].start+keys[i].len]
-		b := utf16buf[keys[j].start : keys[j].start+keys[j].len]
+	// the code units are only needed for sorting, nested objects can reuse
+	// them. keys stays valid even if nested objects grow e.keys.
+	keys := e.keys[base:]
+	sortKeys(keys, e.utf16)
+	e.utf16 = e.utf16[:utf16Base]
 
-		n := min(len(a), len(b))
+	for _, k := range keys {
+		var err error
+		if dst, err = e.flush(dst); err != nil {
+			return dst, err
+		}
 
-		for k := 0; k < n; k++ {
-			if a[k] != b[k] {
-				return a[k] < b[k]
+		start := e.offset(dst)
+		if start > dstLen+1 {
+			dst = append(dst, ',')
+		}
+
+		// key
+		dst, err = appendString(dst, k.raw)
+		if err != nil {
+			return e.truncate(dst, dstLen), err
+		}
+
+		dst = append(dst, ':')
+		mark := len(e.violations)
+
+		v := obj[k.raw]
+		dst, err = e.append(dst, v)
+		if err != nil || len(e.violations) > mark {
+			if e.dropped(err) {
+				dst = e.truncate(dst, start)
+				continue
+			}
+			if err = e.memberError(err, mark, k.raw, reflect.TypeOf(v)); err != nil {
+				return e.truncate(dst, dstLen), err
 			}
 		}
-		return len(a) < len(b)
+	}
+
+	dst = append(dst, '}')
+	return dst, nil
+}
+
+// CompareKeys compares two object member names in the order required by
+// RFC 8785, i.e. by their UTF-16 code units rather than by their UTF-8
+// bytes. The result is -1 if a sorts before b, +1 if it sorts after and 0 if
+// both names are equal. The two orders differ for characters outside the
+// Basic Multilingual Plane: "\U0001F600" sorts before "\uFB01".
+//
+// a and b are assumed to be valid UTF-8, as member names encoded by Append
+// are. Names that differ only in invalid bytes, which compare as U+FFFD,
+// are ordered by their bytes.
+//
+// CompareKeys is intended for implementations of Marshaler that emit members
+// whose names are not known in advance.
+func CompareKeys(a, b string) int {
+	return compareUTF16(a, b)
+}
+
+// SortKeys sorts object member names in place in the order required by
+// RFC 8785, as defined by CompareKeys. Like CompareKeys, it assumes valid
+// UTF-8.
+func SortKeys(keys []string) {
+	slices.SortFunc(keys, compareUTF16)
+}
+
+// sortKeys sorts keys in place by the UTF-16 code units stored for each key in
+// utf16buf, which is the member ordering required by RFC 8785 section 3.2.3.
+func sortKeys(keys []kv, utf16buf []uint16) {
+	slices.SortFunc(keys, func(x, y kv) int {
+		return slices.Compare(
+			utf16buf[x.start:x.start+x.len],
+			utf16buf[y.start:y.start+y.len],
+		)
 	})
+}
 
-	for i, k := range keys {
-		if i > 0 {
-			dst = append(dst, ',')
+// appendMap serializes a map value whose keys can be converted to member
+// names by mapKey, such as map[string]string, map[Name]*Order, map[int]T or
+// map[netip.Addr]T, into the destination byte slice dst.
+//
+// It is the reflection counterpart of appendObject and follows the same
+// rules: member names are validated and sorted by their UTF-16 code units,
+// so integer keys are ordered as strings ("10" before "9"), and each value
+// is encoded with Append. A nil map is encoded as {} just like an empty
+// map[string]any.
+//
+// ErrDuplicateKey is returned if two keys are converted to the same member
+// name, which can happen for keys implementing encoding.TextMarshaler, and
+// for invalid member names repaired when e replaces invalid UTF-8.
+func (e *Encoder) appendMap(dst []byte, v reflect.Value) ([]byte, error) {
+	if err := e.enter(); err != nil {
+		return dst, err
+	}
+	defer e.leave()
+
+	dstLen := e.offset(dst)
+	dst = append(dst, '{')
+	if v.Len() == 0 {
+		return append(dst, '}'), nil
+	}
+
+	if err := e.checkMembers(v.Len()); err != nil {
+		return e.truncate(dst, dstLen), err
+	}
+
+	ptr := v.UnsafePointer()
+	if err := e.enterRef(ptr, 0); err != nil {
+		return e.truncate(dst, dstLen), err
+	}
+	defer e.leaveRef(ptr, 0)
+
+	base, valsBase := len(e.keys), len(e.vals)
+	defer e.popKeys(base)
+	defer e.popVals(valsBase)
+
+	utf16Base := len(e.utf16)
+	e.utf16 = slices.Grow(e.utf16, v.Len()*8)
+	e.keys = slices.Grow(e.keys, v.Len())
+	e.vals = slices.Grow(e.vals, v.Len())
+
+	// distinct strings and integers give distinct names, texts may not
+	kt := v.Type().Key()
+	checkDuplicates := kt.Kind() != reflect.String && kt.Implements(textMarshalerType)
+
+	iter := v.MapRange()
+	for iter.Next() {
+		start := len(e.utf16)
+		var n int
+
+		k, err := mapKey(iter.Key())
+		if err == nil && e.replaceInvalidUTF8 && !utf8.ValidString(k) {
+			k, checkDuplicates = toValidUTF8(k), true
+		}
+		if err == nil {
+			e.utf16, n, err = appendUTF16(e.utf16, k)
+		}
+		if err == nil {
+			err = e.checkString(k)
+		}
+		if err != nil {
+			e.utf16 = e.utf16[:start]
+			if err = e.keyError(err, v.Type()); err != nil {
+				e.utf16 = e.utf16[:utf16Base]
+				return e.truncate(dst, dstLen), err
+			}
+			continue
+		}
+
+		e.keys = append(e.keys, kv{
+			raw:   k,
+			len:   n,
+			start: start,
+			idx:   len(e.vals),
+		})
+		e.vals = append(e.vals, iter.Value())
+	}
+
+	keys, vals := e.keys[base:], e.vals
+	sortKeys(keys, e.utf16)
+	e.utf16 = e.utf16[:utf16Base]
+
+	// distinct keys may have been converted or repaired to the same name
+	if checkDuplicates {
+		for i := 1; i < len(keys); i++ {
+			if keys[i].raw == keys[i-1].raw {
+				return e.truncate(dst, dstLen), ErrDuplicateKey
+			}
 		}
+	}
 
+	for _, k := range keys {
 		var err error
+		if dst, err = e.flush(dst); err != nil {
+			return dst, err
+		}
+
+		start := e.offset(dst)
+		if start > dstLen+1 {
+			dst = append(dst, ',')
+		}
 
 		// key
 		dst, err = appendString(dst, k.raw)
 		if err != nil {
-			return dst[:dstLen], err
+			return e.truncate(dst, dstLen), err
 		}
 
 		dst = append(dst, ':')
-		dst, err = Append(dst, obj[k.raw])
-		if err != nil {
-			return dst[:dstLen], err
+		mark := len(e.violations)
+
+		v := vals[k.idx].Interface()
+		dst, err = e.append(dst, v)
+		if err != nil || len(e.violations) > mark {
+			if e.dropped(err) {
+				dst = e.truncate(dst, start)
+				continue
+			}
+			if err = e.memberError(err, mark, k.raw, reflect.TypeOf(v)); err != nil {
+				return e.truncate(dst, dstLen), err
+			}
 		}
 	}
 
 	dst = append(dst, '}')
 	return dst, nil
 }
+
+// popKeys truncates the key stack of e back to n entries, clearing the
+// popped ones.
+func (e *Encoder) popKeys(n int) {
+	clear(e.keys[n:])
+	e.keys = e.keys[:n]
+}
+
+// popVals truncates the map value stack of e back to n entries, clearing
+// the popped ones.
+func (e *Encoder) popVals(n int) {
+	clear(e.vals[n:])
+	e.vals = e.vals[:n]
+}
+
+var textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+
+// isMapKey reports whether the keys of maps of type t can be converted to
+// member names by mapKey: their kind is string or an integer kind, or they
+// implement encoding.TextMarshaler, as for encoding/json.
+func isMapKey(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+
+	return t.Implements(textMarshalerType)
+}
+
+// mapKey returns the member name of the map key k with the rules of
+// encoding/json: a string is used as is, the result of MarshalText is used
+// for an encoding.TextMarshaler (a nil pointer gives ""), and an integer is
+// written in decimal, with no safe range limit since it becomes a string.
+func mapKey(k reflect.Value) (string, error) {
+	if k.Kind() == reflect.String {
+		return k.String(), nil
+	}
+
+	if m, ok := k.Interface().(encoding.TextMarshaler); ok {
+		if k.Kind() == reflect.Pointer && k.IsNil() {
+			return "", nil
+		}
+
+		b, err := m.MarshalText()
+		if err != nil {
+			return "", fmt.Errorf("jcs: calling MarshalText for type %s: %w", k.Type(), err)
+		}
+		return string(b), nil
+	}
+
+	switch k.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10), nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(k.Uint(), 10), nil
+	}
+
+	return "", ErrUnsupportedType
+}
@@ -54,13 +54,106 @@ func TestAppendObject(t *testing.T) {
 			"",
 			ErrUnsupportedType,
 		},
-		{"ErrUnsupportedType", map[string]any{"err": errors.New("fail")}, "", ErrUnsupportedType},
+		{"ErrUnsupportedType", map[string]any{"ch": make(chan int)}, "", ErrUnsupportedType},
 	}
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			got, err := appendObject([]byte{}, tc.value)
-			Equals(t, tc.wantErr, err)
+			got, err := NewEncoder().appendObject([]byte{}, tc.value)
+			Equals(t, true, errors.Is(err, tc.wantErr))
+			Equals(t, tc.want, string(got))
+		})
+	}
+}
+
+func TestSortKeys(t *testing.T) {
+	keys := []string{"ﬁ", "b", "😀", "A", "a", "\u0080", "aa"}
+	SortKeys(keys)
+	Equals(t, []string{"A", "a", "aa", "b", "\u0080", "😀", "ﬁ"}, keys)
+
+	// SortKeys must agree with the ordering used by appendObject
+	obj := make(map[string]any, len(keys))
+	want := []byte{'{'}
+	for i, k := range keys {
+		obj[k] = i
+		if i > 0 {
+			want = append(want, ',')
+		}
+		want, _ = AppendString(want, k)
+		want = append(want, ':')
+		want, _ = Append(want, i)
+	}
+	want = append(want, '}')
+
+	got, err := NewEncoder().appendObject(nil, obj)
+	Equals(t, nil, err)
+	Equals(t, string(want), string(got))
+
+	Equals(t, -1, CompareKeys("😀", "ﬁ"))
+	Equals(t, 0, CompareKeys("a", "a"))
+
+	// names differing only in invalid UTF-8 sort the same in any order
+	invalid := []string{"a\xff", "a\xfe", "b", "a\xfd"}
+	for range 10 {
+		SortKeys(invalid)
+		Equals(t, []string{"a\xfd", "a\xfe", "a\xff", "b"}, invalid)
+		invalid[0], invalid[2] = invalid[2], invalid[0]
+	}
+}
+
+type gridPoint struct{ X, Y int }
+
+func (p gridPoint) MarshalText() ([]byte, error) {
+	return []byte(strconv.Itoa(p.X) + "," + strconv.Itoa(p.Y)), nil
+}
+
+// parity implements encoding.TextMarshaler, which takes precedence over
+// its integer kind and maps distinct keys to the same name.
+type parity int
+
+func (p parity) MarshalText() ([]byte, error) {
+	if p%2 == 0 {
+		return []byte("even"), nil
+	}
+	return []byte("odd"), nil
+}
+
+var errNoText = errors.New("no text")
+
+type failingKey struct{}
+
+func (failingKey) MarshalText() ([]byte, error) {
+	return nil, errNoText
+}
+
+func TestAppendMapKeys(t *testing.T) {
+	testCases := []struct {
+		name    string
+		value   any
+		want    string
+		wantErr error
+	}{
+		{name: "Int", value: map[int]string{10: "a", 9: "b", -1: "c", 100: "d"}, want: `{"-1":"c","10":"a","100":"d","9":"b"}`},
+		{name: "Int8", value: map[int8]bool{-128: true, 127: false}, want: `{"-128":true,"127":false}`},
+		{name: "Uint64", value: map[uint64]int{1<<64 - 1: 1, 0: 2}, want: `{"0":2,"18446744073709551615":1}`},
+		{name: "NamedInt", value: map[time.Month]int{time.December: 12, time.January: 1}, want: `{"1":1,"12":12}`},
+		{name: "Nested", value: map[string]any{"m": map[uint16][]int{7: {1}}}, want: `{"m":{"7":[1]}}`},
+		{name: "Empty", value: map[int]int{}, want: `{}`},
+
+		{name: "TextMarshaler", value: map[gridPoint]string{{2, 1}: "b", {10, 0}: "a"}, want: `{"10,0":"a","2,1":"b"}`},
+		{name: "TextMarshalerInt", value: map[parity]int{1: 1, 2: 2}, want: `{"even":2,"odd":1}`},
+		{name: "TextMarshalerPointer", value: map[*gridPoint]int{nil: 0, {1, 1}: 1}, want: `{"":0,"1,1":1}`},
+
+		{name: "ErrDuplicateKey", value: map[parity]int{1: 1, 3: 3}, wantErr: ErrDuplicateKey},
+		{name: "ErrMarshalText", value: map[failingKey]int{{}: 1}, wantErr: errNoText},
+		{name: "ErrFloatKey", value: map[float64]int{1: 1}, wantErr: ErrUnsupportedType},
+		{name: "ErrStructKey", value: map[struct{ A int }]int{{1}: 1}, wantErr: ErrUnsupportedType},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Append(nil, tc.value)
+			Equals(t, true, errors.Is(err, tc.wantErr))
 			Equals(t, tc.want, string(got))
 		})
 	}
@@ -83,10 +176,11 @@ func BenchmarkAppendObject(b *testing.B) {
 			func(b *testing.B) {
 				dst := buf[:0]
 				sample := randomMap(size, rng)
+				e := NewEncoder()
 
 				b.ResetTimer()
 				for b.Loop() {
-					_, err := appendObject(dst, sample)
+					_, err := e.appendObject(dst, sample)
 					if err != nil {
 						b.Fatal(err)
 						return
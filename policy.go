@@ -0,0 +1,62 @@
+package jcs
+
+import "math"
+
+// The options of this file relax the strict defaults of the encoder for
+// uses where producing some output matters more than rejecting invalid data,
+// such as hashing telemetry for deduplication.
+//
+// They are NOT part of RFC 8785: a value encoded with any of them is no
+// longer rejected where the RFC requires it, and distinct values may share
+// the same output. Do not use them for signatures or anything that must
+// interoperate with other JCS implementations.
+
+// WithNonFiniteAsNull makes the encoder write NaN, +Inf and -Inf as null
+// instead of failing with ErrNaN or ErrInf, like JavaScript's JSON.stringify
+// does. This is not RFC 8785 compliant.
+func WithNonFiniteAsNull() Option {
+	return func(e *Encoder) {
+		e.nonFiniteAsNull = true
+	}
+}
+
+// WithInvalidUTF8Replacement makes the encoder replace every byte of a
+// string or member name that is not part of a valid UTF-8 sequence with
+// U+FFFD, like encoding/json does, instead of failing with ErrInvalidUTF8.
+// Member names that become equal after the replacement fail with
+// ErrDuplicateKey. This is not RFC 8785 compliant.
+//
+// The replacement applies to strings encoded by the encoder itself, not to
+// the output of marshalers or to json.RawMessage values.
+func WithInvalidUTF8Replacement() Option {
+	return func(e *Encoder) {
+		e.replaceInvalidUTF8 = true
+	}
+}
+
+// WithDropUnsupported makes the encoder leave out values that would fail
+// with ErrUnsupportedType, such as functions and channels, instead of
+// failing: an object member with such a value is omitted, an array element
+// is removed and a top-level value is written as null. This
+// is not RFC 8785 compliant.
+func WithDropUnsupported() Option {
+	return func(e *Encoder) {
+		e.dropUnsupported = true
+	}
+}
+
+// appendFloat appends v with appendNumber, or null for NaN and ±Inf when e
+// is configured with WithNonFiniteAsNull.
+func (e *Encoder) appendFloat(dst []byte, v float64) ([]byte, error) {
+	if e.nonFiniteAsNull && (math.IsNaN(v) || math.IsInf(v, 0)) {
+		return append(dst, 'n', 'u', 'l', 'l'), nil
+	}
+
+	return appendNumber(dst, v)
+}
+
+// dropped reports whether err is the error of an unsupported value that e
+// leaves out of its enclosing array or object.
+func (e *Encoder) dropped(err error) bool {
+	return e.dropUnsupported && err == ErrUnsupportedType
+}
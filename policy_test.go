@@ -0,0 +1,67 @@
+package jcs
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+type policyRecord struct {
+	A float32 `json:"a"`
+	B func()  `json:"b"`
+	C string  `json:"c"`
+}
+
+func TestEncoderPolicies(t *testing.T) {
+	bad := string([]byte{'a', 0xff, 'b'})
+	surrogate := string([]byte{0xed, 0xa0, 0x80})
+
+	testCases := []struct {
+		name    string
+		opts    []Option
+		value   any
+		want    string
+		wantErr error
+	}{
+		{name: "NaN", opts: []Option{WithNonFiniteAsNull()}, value: math.NaN(), want: `null`},
+		{name: "Inf", opts: []Option{WithNonFiniteAsNull()}, value: []any{math.Inf(1), float32(math.Inf(-1)), 1.5}, want: `[null,null,1.5]`},
+		{name: "InfInMap", opts: []Option{WithNonFiniteAsNull()}, value: map[string]float64{"x": math.Inf(-1)}, want: `{"x":null}`},
+		{name: "NaNStrict", value: math.NaN(), wantErr: ErrNaN},
+
+		{name: "InvalidUTF8", opts: []Option{WithInvalidUTF8Replacement()}, value: bad, want: "\"a\ufffdb\""},
+		{name: "Surrogate", opts: []Option{WithInvalidUTF8Replacement()}, value: surrogate, want: "\"\ufffd\ufffd\ufffd\""},
+		{name: "InvalidUTF8Names", opts: []Option{WithInvalidUTF8Replacement()}, value: map[string]any{bad: bad, "a": 1}, want: "{\"a\":1,\"a\ufffdb\":\"a\ufffdb\"}"},
+		{name: "InvalidUTF8TypedMap", opts: []Option{WithInvalidUTF8Replacement()}, value: map[string]int{"\xff": 1}, want: "{\"\ufffd\":1}"},
+		{name: "InvalidUTF8Collision", opts: []Option{WithInvalidUTF8Replacement()}, value: map[string]any{"\xfe": 1, "\xff": 2}, wantErr: ErrDuplicateKey},
+		{name: "InvalidUTF8Strict", value: bad, wantErr: ErrInvalidUTF8},
+		{name: "InvalidUTF8MarshalText", opts: []Option{WithInvalidUTF8Replacement()}, value: marshalText(bad), want: "\"text:a\ufffdb\""},
+		{name: "InvalidUTF8MarshalTextStrict", value: marshalText(bad), wantErr: ErrInvalidUTF8},
+
+		{name: "DropTopLevel", opts: []Option{WithDropUnsupported()}, value: func() {}, want: `null`},
+		{name: "DropElements", opts: []Option{WithDropUnsupported()}, value: []any{make(chan int), 1, complex(1, 2), 2, func() {}}, want: `[1,2]`},
+		{name: "DropAllElements", opts: []Option{WithDropUnsupported()}, value: [2]any{complex(1, 2), func() {}}, want: `[]`},
+		{name: "DropMembers", opts: []Option{WithDropUnsupported()}, value: map[string]any{"a": func() {}, "b": 1, "c": make(chan int)}, want: `{"b":1}`},
+		{name: "DropTypedMap", opts: []Option{WithDropUnsupported()}, value: map[string]func(){"a": nil, "b": func() {}}, want: `{}`},
+		{name: "DropField", opts: []Option{WithDropUnsupported()}, value: policyRecord{A: 1, B: func() {}, C: "x"}, want: `{"a":1,"c":"x"}`},
+		{name: "DropStrict", value: []any{func() {}}, wantErr: ErrUnsupportedType},
+
+		{name: "Combined", opts: []Option{WithNonFiniteAsNull(), WithInvalidUTF8Replacement(), WithDropUnsupported()}, value: []any{math.NaN(), "\xff", func() {}}, want: "[null,\"\ufffd\"]"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := NewEncoder(tc.opts...).Append(nil, tc.value)
+			Equals(t, true, errors.Is(err, tc.wantErr))
+			Equals(t, tc.want, string(got))
+		})
+	}
+}
+
+func TestEncoderPoliciesKeepDefaults(t *testing.T) {
+	// configuring one encoder leaves Append strict
+	_, err := NewEncoder(WithNonFiniteAsNull()).Append(nil, math.NaN())
+	Equals(t, nil, err)
+
+	_, err = Append(nil, math.NaN())
+	Equals(t, ErrNaN, err)
+}
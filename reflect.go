@@ -0,0 +1,77 @@
+package jcs
+
+import "reflect"
+
+// appendReflect appends the canonical JSON representation of v using
+// reflection.
+//
+// It is the fallback used by Append for values whose dynamic type is not
+// listed in its type switch. Named types are dispatched on their underlying
+// kind, so a `type Status string` is encoded exactly like a string and a
+// `type Cents int64` exactly like an int64, including the safe integer range
+// check. Composite kinds are handled as follows:
+//   - structs are encoded as JSON objects by appendStruct;
+//   - slices and arrays of any element type by appendArray, or as strings
+//     by appendByteArray for bytes with WithBytesFormat;
+//   - maps whose keys are strings, integers or encoding.TextMarshaler
+//     by appendMap;
+//   - pointers and interfaces are followed, nil encodes as null.
+//
+// Error handling:
+//   - Returns ErrCycle for a pointer, map or slice that contains itself.
+//   - Returns ErrUnsupportedType for kinds that have no JSON representation
+//     (functions, channels, complex numbers, unsafe pointers, ...) and for
+//     maps with other keys, such as floats or structs.
+//   - Otherwise propagates the error of the value encoder for v's kind.
+func (e *Encoder) appendReflect(dst []byte, v reflect.Value) ([]byte, error) {
+	switch v.Kind() {
+	case reflect.Bool:
+		return e.append(dst, v.Bool())
+
+	case reflect.String:
+		return e.appendString(dst, v.String())
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return e.append(dst, v.Int())
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return e.append(dst, v.Uint())
+
+	case reflect.Float32:
+		return e.append(dst, float32(v.Float()))
+
+	case reflect.Float64:
+		return e.append(dst, v.Float())
+
+	case reflect.Struct:
+		return e.appendStruct(dst, v)
+
+	case reflect.Slice, reflect.Array:
+		if e.bytesFormat != BytesArray && v.Type().Elem().Kind() == reflect.Uint8 {
+			return e.appendByteArray(dst, v)
+		}
+		return e.appendArray(dst, v)
+
+	case reflect.Map:
+		if !isMapKey(v.Type().Key()) {
+			return dst, ErrUnsupportedType
+		}
+		return e.appendMap(dst, v)
+
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return append(dst, 'n', 'u', 'l', 'l'), nil
+		}
+
+		if v.Kind() == reflect.Pointer {
+			ptr := v.UnsafePointer()
+			if err := e.enterRef(ptr, 0); err != nil {
+				return dst, err
+			}
+			defer e.leaveRef(ptr, 0)
+		}
+		return e.appendValue(dst, v.Elem())
+	}
+
+	return dst, ErrUnsupportedType
+}
@@ -0,0 +1,122 @@
+package jcs
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func reflectTypeOf[T any]() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+type (
+	reflectBool   bool
+	reflectString string
+	reflectInt    int64
+	reflectUint   uint64
+	reflectFloat  float64
+	reflectChan   chan int
+	reflectTags   []string
+	reflectKey    string
+)
+
+type reflectOrder struct {
+	ID    string  `json:"id"`
+	Price float64 `json:"price"`
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestAppendReflect(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   any
+		want    string
+		wantErr error
+	}{
+		{name: "NamedBool", value: reflectBool(true), want: `true`},
+		{name: "NamedString", value: reflectString("a\"b"), want: `"a\"b"`},
+		{name: "NamedInt", value: reflectInt(-42), want: `-42`},
+		{name: "NamedUint", value: reflectUint(42), want: `42`},
+		{name: "NamedFloat", value: reflectFloat(1e21), want: `1e+21`},
+		{name: "Uintptr", value: uintptr(7), want: `7`},
+		{name: "NamedSlice", value: reflectTags{"b", "a"}, want: `["b","a"]`},
+		{name: "NilSlice", value: reflectTags(nil), want: `[]`},
+		{name: "SliceOfMaps", value: []map[string]any{{"b": 1, "a": 2}, {}}, want: `[{"a":2,"b":1},{}]`},
+		{name: "Array", value: [3]float64{1.5, 0, -2}, want: `[1.5,0,-2]`},
+		{name: "EmptyArray", value: [0]int{}, want: `[]`},
+		{name: "MapStringString", value: map[string]string{"b": "1", "a": "2"}, want: `{"a":"2","b":"1"}`},
+		{name: "MapNamedKey", value: map[reflectKey]int{"😀": 1, "ﬁ": 2}, want: `{"😀":1,"ﬁ":2}`},
+		{name: "NilMap", value: map[string]string(nil), want: `{}`},
+		{name: "Pointer", value: ptr("s"), want: `"s"`},
+		{name: "NilPointer", value: (*string)(nil), want: `null`},
+		{name: "PointerToPointer", value: ptr(ptr(1)), want: `1`},
+		{name: "SliceOfPointers", value: []*reflectOrder{{ID: "a", Price: 1.5}, nil}, want: `[{"id":"a","price":1.5},null]`},
+		{name: "SliceOfInterfaces", value: []fmt.Stringer{nil}, want: `[null]`},
+		{name: "ErrNumberOOR", value: reflectInt(math.MaxInt64), wantErr: ErrNumberOOR},
+		{name: "ErrNaNInArray", value: [1]float64{math.NaN()}, wantErr: ErrNaN},
+		{name: "ErrInvalidUTF8Key", value: map[string]int{string([]byte{0xff}): 1}, wantErr: ErrInvalidUTF8},
+		{name: "ErrUnsupportedMapKey", value: map[float64]string{1: "a"}, wantErr: ErrUnsupportedType},
+		{name: "ErrNaN", value: reflectFloat(math.NaN()), wantErr: ErrNaN},
+		{name: "ErrUnsupportedChan", value: make(reflectChan), wantErr: ErrUnsupportedType},
+		{name: "ErrUnsupportedComplex", value: complex(1, 2), wantErr: ErrUnsupportedType},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			out, err := NewEncoder().appendReflect(nil, reflect.ValueOf(tc.value))
+			Equals(t, true, errors.Is(err, tc.wantErr))
+			Equals(t, tc.want, string(out))
+		})
+	}
+}
+
+// reflectError is an error with exported fields.
+type reflectError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *reflectError) Error() string { return e.Message }
+
+// reflectErrorCode is an error that is not a struct.
+type reflectErrorCode string
+
+func (e reflectErrorCode) Error() string { return string(e) }
+
+// reflectErrorEmpty is an error without exported fields.
+type reflectErrorEmpty struct{ msg string }
+
+func (e reflectErrorEmpty) Error() string { return e.msg }
+
+func TestAppendErrorValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   any
+		want    string
+		wantErr error
+	}{
+		{name: "ExportedFields", value: []any{&reflectError{Code: 404, Message: "not found"}}, want: `[{"code":404,"message":"not found"}]`},
+		{name: "String", value: reflectErrorCode("E42"), want: `"E42"`},
+		{name: "Field", value: struct{ Err error }{reflectErrorCode("E42")}, want: `{"Err":"E42"}`},
+		{name: "NilField", value: struct{ Err error }{}, want: `{"Err":null}`},
+		// like encoding/json
+		{name: "Errorf", value: []any{fmt.Errorf("fail")}, want: `[{}]`},
+		{name: "ErrorsNew", value: errors.New("fail"), want: `{}`},
+		{name: "NoExportedFields", value: reflectErrorEmpty{"fail"}, want: `{}`},
+		{name: "NoExportedFieldsPointer", value: &reflectErrorEmpty{"fail"}, want: `{}`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			out, err := Append(nil, tc.value)
+			Equals(t, true, errors.Is(err, tc.wantErr))
+			Equals(t, tc.want, string(out))
+		})
+	}
+}
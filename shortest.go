@@ -0,0 +1,147 @@
+package jcs
+
+import (
+	"math"
+	"math/bits"
+)
+
+// pow10Min and pow10Max bound the exponents of pow10Table, those needed
+// for all finite doubles.
+const (
+	pow10Min = -292
+	pow10Max = 324
+)
+
+// uint128 is an unsigned 128-bit integer.
+type uint128 struct {
+	hi, lo uint64
+}
+
+// shortestDecimal returns the shortest decimal digits × 10^exp that
+// converts back to the finite, positive double v, choosing the one closest
+// to v if there are several, as ECMAScript Number::toString requires.
+// digits has no trailing zeros.
+//
+// It implements the Schubfach algorithm by Raffaello Giulietti, which like
+// Ryu finds the shortest digits with a few 128-bit multiplications by a
+// table of powers of ten, instead of the multi-precision arithmetic of a
+// generic conversion.
+func shortestDecimal(v float64) (digits uint64, exp int) {
+	b := math.Float64bits(v)
+	mantissa := b & (1<<52 - 1)
+	biased := int(b >> 52 & (1<<11 - 1))
+
+	// v = c × 2^q
+	c, q := mantissa, -1074
+	if biased > 0 {
+		c, q = mantissa|1<<52, biased-1075
+	}
+
+	// the bounds of the rounding interval of v are included if c is even,
+	// since they round to v with round-half-even
+	even := c&1 == 0
+
+	// the interval is asymmetric if v is a power of two above the smallest
+	// normal, its lower neighbour being closer
+	closer := mantissa == 0 && biased > 1
+
+	// 4 × the lower bound, v and the upper bound of the interval, × 2^(q-2)
+	cbl := 4*c - 2
+	if closer {
+		cbl++
+	}
+	cb := 4 * c
+	cbr := 4*c + 2
+
+	k := floorLog10Pow2(q)
+	if closer {
+		k = floorLog10ThreeQuartersPow2(q)
+	}
+	h := uint(q + floorLog2Pow10(-k) + 1)
+	g := pow10Table[-k-pow10Min]
+
+	// the interval scaled by 10^-k, with 2 fractional bits
+	vbl := roundToOdd(g, cbl<<h)
+	vb := roundToOdd(g, cb<<h)
+	vbr := roundToOdd(g, cbr<<h)
+
+	lower, upper := vbl, vbr
+	if !even {
+		lower++
+		upper--
+	}
+
+	s := vb / 4
+	if s >= 10 {
+		// one digit less, if a single candidate is in the interval
+		sp := s / 10
+		upInside := lower <= 40*sp
+		wpInside := 40*sp+40 <= upper
+		if upInside != wpInside {
+			if wpInside {
+				sp++
+			}
+			return trimZeros(sp, k+1)
+		}
+	}
+
+	uInside := lower <= 4*s
+	wInside := 4*s+4 <= upper
+	if uInside != wInside {
+		if wInside {
+			s++
+		}
+		return trimZeros(s, k)
+	}
+
+	// both candidates are in the interval, take the closest, or the even
+	// one on a tie
+	mid := 4*s + 2
+	if vb > mid || (vb == mid && s&1 != 0) {
+		s++
+	}
+
+	return trimZeros(s, k)
+}
+
+// roundToOdd returns the 64 high bits of the 192-bit product g × cp,
+// shifted right by 128, with its lowest bit set if any of the discarded
+// bits, beyond the error of g, is set.
+func roundToOdd(g uint128, cp uint64) uint64 {
+	x1, _ := bits.Mul64(g.lo, cp)
+	y1, y0 := bits.Mul64(g.hi, cp)
+
+	z, carry := bits.Add64(y0, x1, 0)
+	y1 += carry
+
+	if z > 1 {
+		y1 |= 1
+	}
+
+	return y1
+}
+
+// trimZeros removes the trailing zeros of digits, adjusting exp.
+func trimZeros(digits uint64, exp int) (uint64, int) {
+	for digits%10 == 0 {
+		digits /= 10
+		exp++
+	}
+
+	return digits, exp
+}
+
+// floorLog10Pow2 returns ⌊log10(2^e)⌋ for |e| ≤ 2620.
+func floorLog10Pow2(e int) int {
+	return e * 315653 >> 20
+}
+
+// floorLog2Pow10 returns ⌊log2(10^e)⌋ for |e| ≤ 1233.
+func floorLog2Pow10(e int) int {
+	return e * 1741647 >> 19
+}
+
+// floorLog10ThreeQuartersPow2 returns ⌊log10(3/4 × 2^e)⌋ for |e| ≤ 2620.
+func floorLog10ThreeQuartersPow2(e int) int {
+	return (e*315653 - 131237) >> 20
+}
@@ -0,0 +1,369 @@
+package jcs
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/binary"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"unsafe"
+)
+
+// WithMemoryBudget makes TransformStream sort the members of a top-level
+// object externally, holding about n bytes of them in memory: once the
+// canonicalized members read so far exceed n bytes, they are sorted and
+// written to a temporary file as a run, and the runs are merged into the
+// output at the end of the object. n <= 0 means no budget, which is the
+// default: a top-level object is read and sorted whole in memory.
+//
+// The budget does not cover a single member, which is always held whole,
+// nor the buffers used to read the runs back, about 64 KiB each at most.
+// It has no effect on Append or StreamEncoder, whose maps are already held
+// in memory.
+func WithMemoryBudget(n int) Option {
+	return func(e *Encoder) {
+		e.memoryBudget = max(n, 0)
+	}
+}
+
+// WithTempDir sets the directory of the temporary file written by
+// TransformStream under WithMemoryBudget. The default is os.TempDir.
+func WithTempDir(dir string) Option {
+	return func(e *Encoder) {
+		e.tempDir = dir
+	}
+}
+
+// maxRunBuffer bounds the buffer used to read each run back while merging.
+const maxRunBuffer = 64 << 10
+
+// memberSorter sorts the members of a top-level object read by
+// TransformStream, spilling sorted runs to a temporary file when they
+// exceed the memory budget.
+type memberSorter struct {
+	budget int
+	dir    string
+
+	// units holds the UTF-16 code units of the member names and text the
+	// canonical `"name":value` members, indexed by members.
+	units   []uint16
+	text    []byte
+	members []runMember
+
+	// file holds the runs written so far, one after the other, and size
+	// its length.
+	file *os.File
+	w    *bufio.Writer
+	size int64
+	runs []run
+
+	// record is the encoding of the member being spilled.
+	record []byte
+}
+
+// runMember is a member held in memory by a memberSorter.
+type runMember struct {
+	// name and text delimit the UTF-16 code units of the member name in
+	// memberSorter.units and the canonical member in memberSorter.text.
+	name, nameEnd int
+	text, textEnd int
+
+	pos position
+}
+
+// position is the position of a member name in the input, kept to report
+// duplicate names.
+type position struct {
+	offset       int64
+	line, column int
+}
+
+// run is a sorted run of members, written to a memberSorter's file from
+// offset start to end.
+type run struct {
+	start, end int64
+}
+
+// add records a member whose name has the UTF-16 code units units and
+// whose canonical form is text, spilling the members held in memory once
+// they exceed the budget.
+func (m *memberSorter) add(units []uint16, text []byte, pos position) error {
+	m.members = append(m.members, runMember{
+		name:    len(m.units),
+		nameEnd: len(m.units) + len(units),
+		text:    len(m.text),
+		textEnd: len(m.text) + len(text),
+		pos:     pos,
+	})
+	m.units = append(m.units, units...)
+	m.text = append(m.text, text...)
+
+	if m.budget > 0 && m.memory() > m.budget {
+		return m.spill()
+	}
+
+	return nil
+}
+
+// memory returns the approximate number of bytes held by the members in
+// memory.
+func (m *memberSorter) memory() int {
+	return 2*len(m.units) + len(m.text) + len(m.members)*int(unsafe.Sizeof(runMember{}))
+}
+
+// sort sorts the members held in memory by name.
+func (m *memberSorter) sort() {
+	slices.SortFunc(m.members, func(a, b runMember) int {
+		return slices.Compare(m.units[a.name:a.nameEnd], m.units[b.name:b.nameEnd])
+	})
+}
+
+// spill sorts the members held in memory and writes them to the file as a
+// new run. Each member is written as the number of code units of its name,
+// the code units, the length of its text, the text and its position, all
+// numbers as uvarints.
+func (m *memberSorter) spill() error {
+	if m.file == nil {
+		f, err := os.CreateTemp(m.dir, "jcs-members-*")
+		if err != nil {
+			return err
+		}
+		m.file = f
+		m.w = bufio.NewWriterSize(f, maxRunBuffer)
+	}
+
+	m.sort()
+
+	r := run{start: m.size}
+	for _, rm := range m.members {
+		name := m.units[rm.name:rm.nameEnd]
+
+		b := binary.AppendUvarint(m.record[:0], uint64(len(name)))
+		for _, u := range name {
+			b = binary.LittleEndian.AppendUint16(b, u)
+		}
+		b = binary.AppendUvarint(b, uint64(rm.textEnd-rm.text))
+		b = append(b, m.text[rm.text:rm.textEnd]...)
+		b = binary.AppendUvarint(b, uint64(rm.pos.offset))
+		b = binary.AppendUvarint(b, uint64(rm.pos.line))
+		b = binary.AppendUvarint(b, uint64(rm.pos.column))
+		m.record = b
+
+		if _, err := m.w.Write(b); err != nil {
+			return err
+		}
+		m.size += int64(len(b))
+	}
+	r.end = m.size
+	m.runs = append(m.runs, r)
+
+	m.units, m.text = m.units[:0], m.text[:0]
+	m.members = m.members[:0]
+
+	return nil
+}
+
+// writeTo writes the object made of all the members added to m, sorted by
+// name, to w. It returns a *SyntaxError wrapping ErrDuplicateKey if two
+// members have the same name, after the members before them have been
+// written.
+func (m *memberSorter) writeTo(w *bufio.Writer) error {
+	if m.file != nil {
+		return m.merge(w)
+	}
+
+	m.sort()
+
+	if err := w.WriteByte('{'); err != nil {
+		return err
+	}
+	for i, rm := range m.members {
+		if i > 0 {
+			prev := m.members[i-1]
+			if slices.Equal(m.units[prev.name:prev.nameEnd], m.units[rm.name:rm.nameEnd]) {
+				return duplicateKey(prev.pos, rm.pos)
+			}
+			if err := w.WriteByte(','); err != nil {
+				return err
+			}
+		}
+		if _, err := w.Write(m.text[rm.text:rm.textEnd]); err != nil {
+			return err
+		}
+	}
+
+	return w.WriteByte('}')
+}
+
+// merge spills the members held in memory as a last run, then merges all
+// runs into w.
+func (m *memberSorter) merge(w *bufio.Writer) error {
+	if len(m.members) > 0 {
+		if err := m.spill(); err != nil {
+			return err
+		}
+	}
+	if err := m.w.Flush(); err != nil {
+		return err
+	}
+
+	// share the budget between the read buffers, 16 bytes being the
+	// smallest size of a bufio.Reader
+	size := min(max(m.budget/len(m.runs), 16), maxRunBuffer)
+
+	var h runHeap
+	for _, r := range m.runs {
+		rr := &runReader{r: bufio.NewReaderSize(io.NewSectionReader(m.file, r.start, r.end-r.start), size)}
+		switch err := rr.next(); err {
+		case nil:
+			h = append(h, rr)
+		case io.EOF:
+		default:
+			return err
+		}
+	}
+	heap.Init(&h)
+
+	if err := w.WriteByte('{'); err != nil {
+		return err
+	}
+
+	var prev []uint16
+	var prevPos position
+	for i := 0; len(h) > 0; i++ {
+		rr := h[0]
+		if i > 0 {
+			if slices.Equal(prev, rr.name) {
+				return duplicateKey(prevPos, rr.pos)
+			}
+			if err := w.WriteByte(','); err != nil {
+				return err
+			}
+		}
+		if _, err := w.Write(rr.text); err != nil {
+			return err
+		}
+		prev, prevPos = append(prev[:0], rr.name...), rr.pos
+
+		switch err := rr.next(); err {
+		case nil:
+			heap.Fix(&h, 0)
+		case io.EOF:
+			heap.Pop(&h)
+		default:
+			return err
+		}
+	}
+
+	return w.WriteByte('}')
+}
+
+// close removes the temporary file of m, if any.
+func (m *memberSorter) close() error {
+	if m.file == nil {
+		return nil
+	}
+
+	err := m.file.Close()
+	if rmErr := os.Remove(m.file.Name()); err == nil {
+		err = rmErr
+	}
+
+	return err
+}
+
+// duplicateKey returns the error reported for two members with the same
+// name at a and b, located at the later one like Transform does.
+func duplicateKey(a, b position) error {
+	if a.offset > b.offset {
+		b = a
+	}
+
+	return syntaxErrorAt(b, ErrDuplicateKey)
+}
+
+// syntaxErrorAt returns a *SyntaxError wrapping err at pos.
+func syntaxErrorAt(pos position, err error) error {
+	return &SyntaxError{
+		Offset: pos.offset,
+		Line:   pos.line,
+		Column: pos.column,
+		Err:    err,
+		msg:    strings.TrimPrefix(err.Error(), "jcs: "),
+	}
+}
+
+// runReader reads the members of a run back, one at a time.
+type runReader struct {
+	r *bufio.Reader
+
+	// name, text and pos are those of the current member.
+	name []uint16
+	text []byte
+	pos  position
+
+	buf []byte
+}
+
+// next reads the next member of the run, returning io.EOF at its end.
+func (rr *runReader) next() error {
+	n, err := binary.ReadUvarint(rr.r)
+	if err != nil {
+		return err
+	}
+
+	rr.buf = slices.Grow(rr.buf[:0], 2*int(n))[:2*n]
+	if _, err := io.ReadFull(rr.r, rr.buf); err != nil {
+		return noEOF(err)
+	}
+	rr.name = rr.name[:0]
+	for i := 0; i < len(rr.buf); i += 2 {
+		rr.name = append(rr.name, binary.LittleEndian.Uint16(rr.buf[i:]))
+	}
+
+	if n, err = binary.ReadUvarint(rr.r); err != nil {
+		return noEOF(err)
+	}
+	rr.text = slices.Grow(rr.text[:0], int(n))[:n]
+	if _, err := io.ReadFull(rr.r, rr.text); err != nil {
+		return noEOF(err)
+	}
+
+	var pos [3]uint64
+	for i := range pos {
+		if pos[i], err = binary.ReadUvarint(rr.r); err != nil {
+			return noEOF(err)
+		}
+	}
+	rr.pos = position{offset: int64(pos[0]), line: int(pos[1]), column: int(pos[2])}
+
+	return nil
+}
+
+// noEOF reports io.EOF in the middle of a member as io.ErrUnexpectedEOF.
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
+}
+
+// runHeap orders the runs being merged by the name of their current
+// member, implementing heap.Interface.
+type runHeap []*runReader
+
+func (h runHeap) Len() int           { return len(h) }
+func (h runHeap) Less(i, j int) bool { return slices.Compare(h[i].name, h[j].name) < 0 }
+func (h runHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *runHeap) Push(x any)        { *h = append(*h, x.(*runReader)) }
+
+func (h *runHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return x
+}
@@ -0,0 +1,152 @@
+package jcs
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestTransformStreamObject(t *testing.T) {
+	inputs := []string{
+		`{}`,
+		` { } `,
+		`{"a":1}`,
+		"{\n  \"b\" : 1.50 ,\"a\\\"}\" : [true, {\"d\":\"}\",\"c\":null}] }\n",
+		`{"😀":1,"ﬁ":2,"é":3,"e":4,"":5}`,
+		`{"z":{"y":{"x":[]}},"a":"\\\\","m":-0,"n":1E3}`,
+		`[{"b":1,"a":2}]`,
+		`"scalar"`,
+	}
+
+	for _, budget := range []int{1, 64, 1 << 20} {
+		for _, in := range inputs {
+			t.Run(strconv.Itoa(budget)+"/"+in, func(t *testing.T) {
+				want, err := Transform(nil, []byte(in))
+				Equals(t, nil, err)
+
+				var out bytes.Buffer
+				err = TransformStream(&out, strings.NewReader(in), WithMemoryBudget(budget), WithTempDir(t.TempDir()))
+				Equals(t, nil, err)
+				Equals(t, string(want), out.String())
+
+				// byte by byte reads
+				out.Reset()
+				err = TransformStream(&out, iotest.OneByteReader(strings.NewReader(in)), WithMemoryBudget(budget), WithTempDir(t.TempDir()))
+				Equals(t, nil, err)
+				Equals(t, string(want), out.String())
+			})
+		}
+	}
+}
+
+func TestTransformStreamObjectErrors(t *testing.T) {
+	// the errors and their positions are those of Transform
+	inputs := []string{
+		`{`,
+		`{ `,
+		`{"a"`,
+		`{"a" 1}`,
+		`{"a":`,
+		`{"a":}`,
+		`{"a":1`,
+		`{"a":1,`,
+		`{"a":1,}`,
+		`{"a":1 "b":2}`,
+		`{"a":1x}`,
+		`{"a":[1}`,
+		`{1:2}`,
+		`{"a":1} x`,
+		`{"a":1}}`,
+		`{"ab`,
+		`{"a\ud800":1}`,
+		`{"a":1,"a":2}`,
+		"{\n  \"b\": 1,\n  \"a\": 2,\n  \"b\": 3\n}",
+		"{\"a\": {\"x\": 1, \"x\": 2}}",
+		`{"a":9007199254740993}`,
+	}
+
+	for _, budget := range []int{1, 1 << 20} {
+		for _, in := range inputs {
+			t.Run(strconv.Itoa(budget)+"/"+strconv.Quote(in), func(t *testing.T) {
+				_, want := Transform(nil, []byte(in))
+				Equals(t, true, want != nil)
+
+				err := TransformStream(io.Discard, strings.NewReader(in), WithMemoryBudget(budget), WithTempDir(t.TempDir()))
+				Equals(t, want.Error(), err.Error())
+
+				var se, wantSE *SyntaxError
+				Equals(t, true, errors.As(err, &se))
+				Equals(t, true, errors.As(want, &wantSE))
+				Equals(t, wantSE.Err, se.Err)
+			})
+		}
+	}
+}
+
+func TestTransformStreamObjectLarge(t *testing.T) {
+	var in bytes.Buffer
+	in.WriteString("{\n")
+	for i, n := range rand.New(rand.NewPCG(1, 2)).Perm(20000) {
+		if i > 0 {
+			in.WriteString(",\n")
+		}
+		in.WriteString(`  "key` + strconv.Itoa(n) + `": {"id": ` + strconv.Itoa(n) + `.0, "tags": ["x", "y"]}`)
+	}
+	in.WriteString("\n}\n")
+
+	want, err := Transform(nil, in.Bytes())
+	Equals(t, nil, err)
+
+	dir := t.TempDir()
+	var out bytes.Buffer
+	err = TransformStream(&out, bytes.NewReader(in.Bytes()), WithMemoryBudget(64<<10), WithTempDir(dir))
+	Equals(t, nil, err)
+	Equals(t, string(want), out.String())
+
+	// the temporary file is removed
+	entries, err := os.ReadDir(dir)
+	Equals(t, nil, err)
+	Equals(t, 0, len(entries))
+}
+
+func TestTransformStreamObjectTempDirError(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "missing")
+
+	err := TransformStream(io.Discard, strings.NewReader(`{"b":1,"a":2}`), WithMemoryBudget(1), WithTempDir(dir))
+	Equals(t, true, errors.Is(err, os.ErrNotExist))
+
+	// nothing to spill within the budget
+	var out bytes.Buffer
+	err = TransformStream(&out, strings.NewReader(`{"b":1,"a":2}`), WithMemoryBudget(1<<20), WithTempDir(dir))
+	Equals(t, nil, err)
+	Equals(t, `{"a":2,"b":1}`, out.String())
+}
+
+func BenchmarkTransformStreamObject(b *testing.B) {
+	b.ReportAllocs()
+
+	var in bytes.Buffer
+	in.WriteString("{")
+	for i := range 10000 {
+		if i > 0 {
+			in.WriteString(",")
+		}
+		in.WriteString(`"key` + strconv.Itoa(i) + `":{"id":` + strconv.Itoa(i) + `}`)
+	}
+	in.WriteString("}")
+	b.SetBytes(int64(in.Len()))
+
+	dir := b.TempDir()
+	for b.Loop() {
+		if err := TransformStream(io.Discard, bytes.NewReader(in.Bytes()), WithMemoryBudget(32<<10), WithTempDir(dir)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
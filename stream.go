@@ -0,0 +1,107 @@
+package jcs
+
+import (
+	"context"
+	"io"
+)
+
+// defaultChunkSize is the number of bytes a StreamEncoder buffers before
+// writing them, unless configured with WithChunkSize.
+const defaultChunkSize = 32 << 10
+
+// WithChunkSize sets the number of bytes a StreamEncoder buffers before it
+// writes them to its io.Writer, 32 KiB by default. Larger chunks mean fewer
+// writes, smaller ones less memory. It has no effect on Append.
+func WithChunkSize(n int) Option {
+	return func(e *Encoder) {
+		e.chunkSize = max(n, 1)
+	}
+}
+
+// StreamEncoder writes the canonical JSON representation of Go values to an
+// io.Writer, such as a file, a network connection or a hash.Hash.
+//
+// Unlike Append, it does not build the whole output in memory: the output
+// is written in chunks as the elements of arrays and the members of objects
+// are encoded, so the memory used does not grow with the size of the
+// output. RFC 8785 still requires the member names of every object to be
+// sorted before its first member is written, and a single element, member
+// value or scalar such as a long string is buffered whole, so memory grows
+// with the largest object and the largest scalar instead.
+//
+// A StreamEncoder is not safe for concurrent use by multiple goroutines.
+type StreamEncoder struct {
+	e   *Encoder
+	buf []byte
+}
+
+// NewStreamEncoder returns a StreamEncoder writing to w, configured by opts
+// like an Encoder returned by NewEncoder.
+func NewStreamEncoder(w io.Writer, opts ...Option) *StreamEncoder {
+	e := NewEncoder(opts...)
+	e.w = w
+	if e.chunkSize == 0 {
+		e.chunkSize = defaultChunkSize
+	}
+
+	return &StreamEncoder{e: e}
+}
+
+// Encode writes the canonical JSON representation of v, encoded like
+// Encoder.Append does, to the writer of s. Nothing is written between
+// successive values: to produce a sequence of documents, separators must
+// be written by the caller.
+//
+// If an error occurs, the part of v encoded before it may already have
+// been written, and the output must be discarded. Errors of the writer are
+// returned as is.
+func (s *StreamEncoder) Encode(v any) error {
+	return s.encode(nil, v)
+}
+
+// EncodeContext is like Encode but stops encoding v with the error of ctx
+// once ctx is done, see Encoder.AppendContext.
+func (s *StreamEncoder) EncodeContext(ctx context.Context, v any) error {
+	return s.encode(ctx, v)
+}
+
+// encode implements Encode and EncodeContext. ctx may be nil.
+func (s *StreamEncoder) encode(ctx context.Context, v any) error {
+	buf, err := s.e.encode(ctx, s.buf[:0], v)
+	if err == nil {
+		_, err = s.e.w.Write(buf)
+	}
+	s.buf = buf[:0]
+
+	return err
+}
+
+// offset returns the position of the end of dst in the output of the
+// current call, counting the bytes already written by a StreamEncoder.
+// Containers record offsets rather than lengths of dst, which is emptied
+// when it is written.
+func (e *Encoder) offset(dst []byte) int {
+	return e.flushed + len(dst)
+}
+
+// truncate returns dst truncated to the output offset n. Bytes already
+// written by a StreamEncoder cannot be taken back: the containers only
+// truncate past a chunk boundary on errors, after which the output is
+// discarded anyway.
+func (e *Encoder) truncate(dst []byte, n int) []byte {
+	return dst[:max(n-e.flushed, 0)]
+}
+
+// flush writes dst to the writer of a StreamEncoder and empties it once it
+// holds a chunk. It is called by containers between their elements, so a
+// chunk never ends with the separator or name of a member that is then
+// dropped by WithDropUnsupported.
+func (e *Encoder) flush(dst []byte) ([]byte, error) {
+	if e.w == nil || len(dst) < e.chunkSize {
+		return dst, nil
+	}
+
+	n, err := e.w.Write(dst)
+	e.flushed += n
+	return dst[:0], err
+}
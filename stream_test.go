@@ -0,0 +1,197 @@
+package jcs
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"strconv"
+	"testing"
+)
+
+// chunkRecorder records the writes it receives.
+type chunkRecorder struct {
+	bytes.Buffer
+	writes  int
+	longest int
+}
+
+func (w *chunkRecorder) Write(p []byte) (int, error) {
+	w.writes++
+	w.longest = max(w.longest, len(p))
+	return w.Buffer.Write(p)
+}
+
+type streamRecord struct {
+	ID    int               `json:"id"`
+	Tags  []string          `json:"tags"`
+	Attrs map[string]any    `json:"attrs"`
+	Nums  map[int]float64   `json:"nums"`
+	Skip  func()            `json:"skip,omitzero"`
+	Refs  []*streamRecord   `json:"refs,omitempty"`
+	Extra map[string]string `json:"extra,omitempty"`
+}
+
+func TestStreamEncoder(t *testing.T) {
+	records := make([]streamRecord, 50)
+	for i := range records {
+		records[i] = streamRecord{
+			ID:    i,
+			Tags:  []string{"a", strconv.Itoa(i)},
+			Attrs: map[string]any{"z": i, "a": []any{true, nil, 1.5}},
+			Nums:  map[int]float64{10: 0.1, 9: float64(i)},
+			Refs:  []*streamRecord{{ID: -i}},
+		}
+	}
+
+	values := []any{
+		nil,
+		"scalar",
+		[]int{},
+		[]any{1, "two", []any{3, map[string]any{"b": 4, "a": 5}}},
+		records,
+		map[string]any{"records": records, "count": len(records)},
+	}
+
+	for _, chunkSize := range []int{1, 7, 100, defaultChunkSize} {
+		t.Run("ChunkSize="+strconv.Itoa(chunkSize), func(t *testing.T) {
+			for i, v := range values {
+				want, err := Append(nil, v)
+				Equals(t, nil, err)
+
+				var w chunkRecorder
+				err = NewStreamEncoder(&w, WithChunkSize(chunkSize)).Encode(v)
+				Equals(t, nil, err)
+				Equals(t, string(want), w.String())
+
+				if i == len(values)-1 && chunkSize < len(want) {
+					Equals(t, true, w.writes > 1)
+				}
+			}
+		})
+	}
+}
+
+func TestStreamEncoderChunks(t *testing.T) {
+	v := make([]int, 100000)
+	for i := range v {
+		v[i] = i
+	}
+
+	var w chunkRecorder
+	err := NewStreamEncoder(&w, WithChunkSize(64)).Encode(v)
+	Equals(t, nil, err)
+
+	// a chunk is written as soon as it is full, elements are short
+	Equals(t, true, w.longest < 64+16)
+	Equals(t, true, w.writes > w.Len()/(64+16))
+
+	want, _ := Append(nil, v)
+	Equals(t, sha256.Sum256(want), sha256.Sum256(w.Bytes()))
+}
+
+func TestStreamEncoderHash(t *testing.T) {
+	v := map[string]any{"b": []string{"x", "y"}, "a": 1}
+	want, _ := Append(nil, v)
+
+	h := sha256.New()
+	err := NewStreamEncoder(h, WithChunkSize(4)).Encode(v)
+	Equals(t, nil, err)
+	Equals(t, sha256.Sum256(want), [sha256.Size]byte(h.Sum(nil)))
+}
+
+func TestStreamEncoderSequence(t *testing.T) {
+	var w bytes.Buffer
+	s := NewStreamEncoder(&w)
+
+	Equals(t, nil, s.Encode(map[string]int{"b": 1, "a": 2}))
+	Equals(t, nil, s.Encode([]int{3}))
+	Equals(t, `{"a":2,"b":1}[3]`, w.String())
+}
+
+func TestStreamEncoderOptions(t *testing.T) {
+	t.Run("DropUnsupported", func(t *testing.T) {
+		// dropped members are never split from their separator and name
+		v := []any{"aaaa", func() {}, map[string]any{"bbbb": 1, "c": make(chan int)}, streamRecord{Skip: func() {}}}
+
+		for chunkSize := 1; chunkSize < 32; chunkSize++ {
+			var w bytes.Buffer
+			err := NewStreamEncoder(&w, WithChunkSize(chunkSize), WithDropUnsupported()).Encode(v)
+			Equals(t, nil, err)
+			Equals(t, `["aaaa",{"bbbb":1},{"attrs":{},"id":0,"nums":{},"tags":[]}]`, w.String())
+		}
+	})
+
+	t.Run("MaxOutputSize", func(t *testing.T) {
+		var w bytes.Buffer
+		err := NewStreamEncoder(&w, WithChunkSize(8), WithMaxOutputSize(50)).Encode(make([]int, 100))
+		Equals(t, true, errors.Is(err, ErrMaxOutputSize))
+		Equals(t, true, w.Len() <= 50)
+	})
+
+	t.Run("Context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		var w bytes.Buffer
+		err := NewStreamEncoder(&w).EncodeContext(ctx, []int{1})
+		Equals(t, context.Canceled, err)
+		Equals(t, 0, w.Len())
+	})
+}
+
+// failingWriter fails every write after the first n bytes.
+type failingWriter struct{ n int }
+
+var errWrite = errors.New("write failed")
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if len(p) > w.n {
+		n := w.n
+		w.n = 0
+		return n, errWrite
+	}
+	w.n -= len(p)
+	return len(p), nil
+}
+
+func TestStreamEncoderErrors(t *testing.T) {
+	t.Run("Value", func(t *testing.T) {
+		var w bytes.Buffer
+		err := NewStreamEncoder(&w, WithChunkSize(4)).Encode([]any{"aaaaaaaa", 1, func() {}})
+
+		var encErr *EncodeError
+		Equals(t, true, errors.As(err, &encErr))
+		Equals(t, "/2", encErr.Path)
+		Equals(t, ErrUnsupportedType, encErr.Err)
+	})
+
+	t.Run("Writer", func(t *testing.T) {
+		err := NewStreamEncoder(&failingWriter{n: 10}, WithChunkSize(4)).Encode(make([]string, 100))
+		Equals(t, errWrite, err)
+	})
+
+	t.Run("WriterLastChunk", func(t *testing.T) {
+		err := NewStreamEncoder(&failingWriter{n: 1}).Encode("abc")
+		Equals(t, errWrite, err)
+	})
+}
+
+func BenchmarkStreamEncoder(b *testing.B) {
+	b.ReportAllocs()
+
+	v := make([]map[string]any, 1000)
+	for i := range v {
+		v[i] = map[string]any{"id": i, "name": "item" + strconv.Itoa(i), "tags": []string{"a", "b"}}
+	}
+
+	h := sha256.New()
+	s := NewStreamEncoder(h)
+
+	for b.Loop() {
+		h.Reset()
+		if err := s.Encode(v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,515 @@
+package jcs
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// TransformStream reads JSON text from r and writes its canonical form, as
+// produced by Transform, to w.
+//
+// When the top-level value is an array, its elements are read, canonicalized
+// and written one at a time, since RFC 8785 keeps the order of array
+// elements: only the largest element has to fit in memory, so exports made
+// of a single huge array of records can be canonicalized in roughly
+// constant memory. Any other top-level value is read whole and passed to
+// Transform.
+//
+// A top-level object with more members than fit in memory can be
+// canonicalized with WithMemoryBudget: its members are then read one at a
+// time and sorted externally, using a temporary file created in the
+// directory set by WithTempDir. WithChunkSize sets the size of the read and
+// write buffers. WithMaxDepth, WithMaxOutputSize, WithMaxMembers and
+// WithMaxStringLength apply as for Encoder.Transform. The other options
+// have no effect.
+//
+// Error handling:
+//   - Errors in the JSON text are *SyntaxError values whose Offset, Line and
+//     Column are positions in the whole input, as for Transform.
+//   - Errors of r and w, and of the temporary file, are returned as is.
+//   - If an error occurs, the elements before it may already have been
+//     written, and the output must be discarded.
+func TransformStream(w io.Writer, r io.Reader, opts ...Option) error {
+	e := NewEncoder(opts...)
+	size := e.chunkSize
+	if size == 0 {
+		size = defaultChunkSize
+	}
+
+	s := &streamTransformer{
+		r:      bufio.NewReaderSize(r, size),
+		line:   1,
+		column: 1,
+		e:      e,
+	}
+	bw := bufio.NewWriterSize(w, size)
+
+	if err := s.transform(bw); err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
+
+// streamTransformer is the state of a single TransformStream call.
+type streamTransformer struct {
+	r *bufio.Reader
+
+	// offset is the number of bytes read from r, and line and column the
+	// position of the next byte.
+	offset       int64
+	line, column int
+
+	// elem holds the text of the element being read, and out its
+	// canonical form.
+	elem []byte
+	out  []byte
+
+	// units holds the UTF-16 code units of the member name being read.
+	units []uint16
+
+	// e holds the limits, and the configuration of the external sort of a
+	// top-level object, see WithMemoryBudget. e.flushed counts the bytes
+	// of output produced so far.
+	e *Encoder
+}
+
+// transform canonicalizes the whole input to w.
+func (s *streamTransformer) transform(w *bufio.Writer) error {
+	if err := s.skipSpace(); err != nil {
+		return err
+	}
+
+	c, err := s.peek()
+	if err != nil && err != io.EOF {
+		return err
+	}
+	switch {
+	case err == nil && c == '[':
+		return s.array(w)
+	case err == nil && c == '{' && s.e.memoryBudget > 0:
+		return s.object(w)
+	default:
+		return s.transformRest(w)
+	}
+}
+
+// array canonicalizes a top-level array to w, one element at a time.
+func (s *streamTransformer) array(w *bufio.Writer) error {
+	s.next()
+	if err := w.WriteByte('['); err != nil {
+		return err
+	}
+	s.e.flushed++
+
+	if err := s.skipSpace(); err != nil {
+		return err
+	}
+	if c, err := s.peek(); err == nil && c == ']' {
+		s.next()
+	} else if err := s.elements(w); err != nil {
+		return err
+	}
+
+	if err := w.WriteByte(']'); err != nil {
+		return err
+	}
+	s.e.flushed++
+
+	if err := s.end(); err != nil {
+		return err
+	}
+
+	return s.checkOutputSize()
+}
+
+// object canonicalizes a top-level object to w, sorting its members with a
+// memberSorter. Nothing is written before the whole input has been read.
+func (s *streamTransformer) object(w *bufio.Writer) (err error) {
+	m := &memberSorter{budget: s.e.memoryBudget, dir: s.e.tempDir}
+	defer func() {
+		if cerr := m.close(); err == nil {
+			err = cerr
+		}
+	}()
+
+	s.next()
+	if err := s.skipSpace(); err != nil {
+		return err
+	}
+	if c, err := s.peek(); err == nil && c == '}' {
+		s.next()
+	} else if err := s.members(m); err != nil {
+		return err
+	}
+	s.e.flushed += 2 // the braces
+
+	if err := s.end(); err != nil {
+		return err
+	}
+	if err := s.checkOutputSize(); err != nil {
+		return err
+	}
+
+	return m.writeTo(w)
+}
+
+// checkOutputSize returns ErrMaxOutputSize at the current position if the
+// output exceeds the output size limit.
+func (s *streamTransformer) checkOutputSize() error {
+	if err := s.e.checkOutputSize(nil); err != nil {
+		return syntaxErrorAt(position{offset: s.offset, line: s.line, column: s.column}, err)
+	}
+
+	return nil
+}
+
+// end checks that only whitespace follows the top-level value.
+func (s *streamTransformer) end() error {
+	if err := s.skipSpace(); err != nil {
+		return err
+	}
+	if c, err := s.peek(); err != io.EOF {
+		if err != nil {
+			return err
+		}
+		return s.errorf("invalid character %s after top-level value", quoteChar(c))
+	}
+
+	return nil
+}
+
+// elements canonicalizes the elements of the top-level array up to its
+// closing bracket, which is consumed.
+func (s *streamTransformer) elements(w *bufio.Writer) error {
+	for {
+		if err := s.skipSpace(); err != nil {
+			return err
+		}
+
+		offset, line, column := s.offset, s.line, s.column
+		if err := s.element(); err != nil {
+			return err
+		}
+
+		var err error
+		if s.out, err = s.transformElement(s.out[:0], "after array element"); err != nil {
+			return locate(err, offset, line, column)
+		}
+		if _, err := w.Write(s.out); err != nil {
+			return err
+		}
+		s.e.flushed += len(s.out)
+
+		if err := s.skipSpace(); err != nil {
+			return err
+		}
+
+		switch c, err := s.peek(); {
+		case err == nil && c == ',':
+			s.next()
+			if err := w.WriteByte(','); err != nil {
+				return err
+			}
+			s.e.flushed++
+		case err == nil && c == ']':
+			s.next()
+			return nil
+		default:
+			return s.unexpected("after array element")
+		}
+	}
+}
+
+// members reads the members of the top-level object up to its closing
+// brace, which is consumed, and adds them to m.
+func (s *streamTransformer) members(m *memberSorter) error {
+	for n := 1; ; n++ {
+		if err := s.skipSpace(); err != nil {
+			return err
+		}
+
+		pos := position{offset: s.offset, line: s.line, column: s.column}
+		if c, err := s.peek(); err != nil || c != '"' {
+			return s.unexpected("looking for beginning of object member name")
+		}
+		if err := s.e.checkMembers(n); err != nil {
+			return syntaxErrorAt(pos, err)
+		}
+		if err := s.element(); err != nil {
+			return err
+		}
+
+		var err error
+		var units []uint16
+		if s.out, units, err = s.transformName(s.out[:0]); err != nil {
+			return locate(err, pos.offset, pos.line, pos.column)
+		}
+
+		if err := s.skipSpace(); err != nil {
+			return err
+		}
+		if c, err := s.peek(); err != nil || c != ':' {
+			return s.unexpected("after object member name")
+		}
+		s.next()
+		s.out = append(s.out, ':')
+
+		if err := s.skipSpace(); err != nil {
+			return err
+		}
+		offset, line, column := s.offset, s.line, s.column
+		if err := s.element(); err != nil {
+			return err
+		}
+		if s.out, err = s.transformElement(s.out, "after object member value"); err != nil {
+			return locate(err, offset, line, column)
+		}
+
+		if err := m.add(units, s.out, pos); err != nil {
+			return err
+		}
+		s.e.flushed += len(s.out)
+		if n > 1 {
+			s.e.flushed++ // the comma before it
+		}
+
+		if err := s.skipSpace(); err != nil {
+			return err
+		}
+		switch c, err := s.peek(); {
+		case err == nil && c == ',':
+			s.next()
+		case err == nil && c == '}':
+			s.next()
+			return nil
+		default:
+			return s.unexpected("after object member value")
+		}
+	}
+}
+
+// element reads the text of the next array element into s.elem. It only
+// finds where the element ends, by matching brackets outside of strings;
+// the element itself is validated by transformElement, which reports
+// malformed or truncated text.
+func (s *streamTransformer) element() error {
+	s.elem = s.elem[:0]
+
+	depth := 0
+	inString, escaped := false, false
+
+	for {
+		// scan the buffered input, reading more when it is exhausted
+		buf, err := s.r.Peek(max(s.r.Buffered(), 1))
+		if len(buf) == 0 {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		n, done := 0, false
+		for ; n < len(buf) && !done; n++ {
+			c := buf[n]
+
+			switch {
+			case inString:
+				switch {
+				case escaped:
+					escaped = false
+				case c == '\\':
+					escaped = true
+				case c == '"':
+					inString = false
+					done = depth == 0
+				}
+
+			case depth == 0 && n+len(s.elem) > 0 && isLiteralEnd(c):
+				// a literal ends at the first byte that cannot be part
+				// of it, which is left for the caller
+				n--
+				done = true
+
+			case c == '"':
+				inString = true
+
+			case c == '[' || c == '{':
+				depth++
+
+			case c == ']' || c == '}':
+				depth--
+				done = depth <= 0
+			}
+		}
+
+		s.elem = append(s.elem, buf[:n]...)
+		s.advance(buf[:n])
+		if done {
+			return nil
+		}
+	}
+}
+
+// transformElement appends the canonical form of s.elem to dst like
+// Transform does, but as an element of the top-level array or a member
+// value of the top-level object, so that errors are reported with the same
+// messages as for the whole input. context describes what may follow the
+// value, for errors.
+func (s *streamTransformer) transformElement(dst []byte, context string) ([]byte, error) {
+	t := transformerPool.Get().(*transformer)
+	defer t.release()
+
+	t.src, t.e = s.elem, s.e
+	t.depth = 1 // the top-level array or object
+
+	dst, err := t.value(dst)
+	if err == nil && t.pos < len(t.src) {
+		err = t.unexpected(context)
+	}
+
+	return dst, err
+}
+
+// transformName appends the canonical form of s.elem, a member name of the
+// top-level object, to dst. It also returns the UTF-16 code units of the
+// name, which are valid until the next call.
+func (s *streamTransformer) transformName(dst []byte) ([]byte, []uint16, error) {
+	t := transformerPool.Get().(*transformer)
+	defer t.release()
+
+	t.src, t.e = s.elem, s.e
+
+	dst, err := t.string(dst, true)
+	s.units = append(s.units[:0], t.utf16...)
+
+	return dst, s.units, err
+}
+
+// transformRest canonicalizes the rest of the input, a top-level value that
+// is not an array, with Transform.
+func (s *streamTransformer) transformRest(w *bufio.Writer) error {
+	offset, line, column := s.offset, s.line, s.column
+
+	src, err := io.ReadAll(s.r)
+	if err != nil {
+		return err
+	}
+
+	if s.out, err = transformWith(s.out[:0], src, s.e); err != nil {
+		return locate(err, offset, line, column)
+	}
+
+	_, err = w.Write(s.out)
+	return err
+}
+
+// peek returns the next byte of the input without consuming it.
+func (s *streamTransformer) peek() (byte, error) {
+	b, err := s.r.Peek(1)
+	if err != nil {
+		return 0, err
+	}
+
+	return b[0], nil
+}
+
+// next consumes the byte returned by peek.
+func (s *streamTransformer) next() {
+	c, _ := s.r.ReadByte()
+
+	s.offset++
+	if c == '\n' {
+		s.line++
+		s.column = 1
+	} else {
+		s.column++
+	}
+}
+
+// advance consumes b, the next bytes of the input returned by Peek.
+func (s *streamTransformer) advance(b []byte) {
+	s.offset += int64(len(b))
+	if i := bytes.LastIndexByte(b, '\n'); i >= 0 {
+		s.line += bytes.Count(b, []byte{'\n'})
+		s.column = len(b) - i
+	} else {
+		s.column += len(b)
+	}
+
+	_, _ = s.r.Discard(len(b)) // b is buffered
+}
+
+// skipSpace consumes insignificant whitespace.
+func (s *streamTransformer) skipSpace() error {
+	for {
+		c, err := s.peek()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch c {
+		case ' ', '\t', '\n', '\r':
+			s.next()
+		default:
+			return nil
+		}
+	}
+}
+
+// unexpected reports the next byte of the input, or its end, as unexpected
+// in the given context, like transformer.unexpected. Errors of the reader
+// are returned as is.
+func (s *streamTransformer) unexpected(context string) error {
+	c, err := s.peek()
+	switch {
+	case err == io.EOF:
+		return s.errorf("unexpected end of JSON input")
+	case err != nil:
+		return err
+	}
+
+	return s.errorf("invalid character %s %s", quoteChar(c), context)
+}
+
+// errorf returns a *SyntaxError at the current position.
+func (s *streamTransformer) errorf(format string, args ...any) error {
+	return &SyntaxError{
+		Offset: s.offset,
+		Line:   s.line,
+		Column: s.column,
+		msg:    fmt.Sprintf(format, args...),
+	}
+}
+
+// isLiteralEnd reports whether c ends a number or literal name.
+func isLiteralEnd(c byte) bool {
+	switch c {
+	case ',', ']', '}', '[', '{', '"', ' ', '\t', '\n', '\r':
+		return true
+	}
+
+	return false
+}
+
+// locate moves the position of a *SyntaxError returned by Transform for a
+// part of the input starting at offset, line and column, to the position in
+// the whole input.
+func locate(err error, offset int64, line, column int) error {
+	var se *SyntaxError
+	if errors.As(err, &se) {
+		if se.Line == 1 {
+			se.Column += column - 1
+		}
+		se.Line += line - 1
+		se.Offset += offset
+	}
+
+	return err
+}
@@ -0,0 +1,138 @@
+package jcs
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestTransformStream(t *testing.T) {
+	inputs := []string{
+		`[]`,
+		` [ ] `,
+		`[1]`,
+		"[\n  1.50 ,\"a\\\"]b\" , true,false ,null, -0 ]\n",
+		`[{"b":[1,{"d":"}","c":"]"}],"a":"\\\\"},[[],{}],"x"]`,
+		`[[1,2],[3,[4,[5]]]]`,
+		`{"b":2,"a":[1,2]}`,
+		`"scalar"`,
+		` 1E3 `,
+		`null`,
+	}
+
+	for _, in := range inputs {
+		t.Run(in, func(t *testing.T) {
+			want, err := Transform(nil, []byte(in))
+			Equals(t, nil, err)
+
+			var out bytes.Buffer
+			err = TransformStream(&out, strings.NewReader(in))
+			Equals(t, nil, err)
+			Equals(t, string(want), out.String())
+
+			// byte by byte reads
+			out.Reset()
+			err = TransformStream(&out, iotest.OneByteReader(strings.NewReader(in)))
+			Equals(t, nil, err)
+			Equals(t, string(want), out.String())
+		})
+	}
+}
+
+func TestTransformStreamErrors(t *testing.T) {
+	// the errors and their positions are those of Transform
+	inputs := []string{
+		``,
+		`   `,
+		`[`,
+		`[1`,
+		`[1,`,
+		`[1,]`,
+		`[1 2]`,
+		`[1}`,
+		`[1]]`,
+		`[1] x`,
+		"[1,\n {\"a\":1,\"a\":2}]",
+		"[\n\n  {\"a\": [1, 9007199254740993]}]",
+		"[ \"abc",
+		`[{"a":1]`,
+		`[tru]`,
+		`[truex]`,
+		`[1.]`,
+		`[[[]]`,
+		`{"a":}`,
+		`"\ud800"`,
+	}
+
+	for _, in := range inputs {
+		t.Run(strconv.Quote(in), func(t *testing.T) {
+			_, want := Transform(nil, []byte(in))
+			Equals(t, true, want != nil)
+
+			err := TransformStream(io.Discard, strings.NewReader(in))
+			Equals(t, want.Error(), err.Error())
+
+			var se, wantSE *SyntaxError
+			Equals(t, true, errors.As(err, &se))
+			Equals(t, true, errors.As(want, &wantSE))
+			Equals(t, wantSE.Err, se.Err)
+		})
+	}
+}
+
+func TestTransformStreamLarge(t *testing.T) {
+	var in bytes.Buffer
+	in.WriteString("[\n")
+	for i := range 10000 {
+		if i > 0 {
+			in.WriteString(",\n")
+		}
+		in.WriteString(`  {"name": "item` + strconv.Itoa(i) + `", "id": ` + strconv.Itoa(i) + `.0, "tags": ["x", "y"]}`)
+	}
+	in.WriteString("\n]\n")
+
+	want, err := Transform(nil, in.Bytes())
+	Equals(t, nil, err)
+
+	var out chunkRecorder
+	err = TransformStream(&out, bytes.NewReader(in.Bytes()))
+	Equals(t, nil, err)
+	Equals(t, string(want), out.String())
+
+	// written in chunks, not as a whole
+	Equals(t, true, out.writes > 1)
+	Equals(t, true, out.longest <= defaultChunkSize)
+}
+
+func TestTransformStreamReadError(t *testing.T) {
+	err := TransformStream(io.Discard, iotest.TimeoutReader(strings.NewReader(`[1,2]`)))
+	Equals(t, iotest.ErrTimeout, err)
+
+	err = TransformStream(io.Discard, io.MultiReader(strings.NewReader(`[1,`), iotest.ErrReader(errWrite)))
+	Equals(t, errWrite, err)
+}
+
+func BenchmarkTransformStream(b *testing.B) {
+	b.ReportAllocs()
+
+	var in bytes.Buffer
+	in.WriteString("[")
+	for i := range 1000 {
+		if i > 0 {
+			in.WriteString(",")
+		}
+		in.WriteString(`{"name":"item` + strconv.Itoa(i) + `","id":` + strconv.Itoa(i) + `}`)
+	}
+	in.WriteString("]")
+	b.SetBytes(int64(in.Len()))
+
+	for b.Loop() {
+		if err := TransformStream(io.Discard, bytes.NewReader(in.Bytes())); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
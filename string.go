@@ -7,6 +7,52 @@ import (
 
 var hex = []byte("0123456789abcdef")
 
+// AppendString appends s to dst as a canonical JSON string, quoted and
+// escaped as required by RFC 8785. It returns ErrInvalidUTF8 if s is not
+// valid UTF-8 or contains surrogate code points, in which case dst is
+// returned unchanged.
+//
+// AppendString is intended for implementations of Marshaler, both for string
+// values and for object member names.
+func AppendString(dst []byte, s string) ([]byte, error) {
+	return appendString(dst, s)
+}
+
+// appendString appends s as a canonical JSON string with appendString,
+// after checking it against the string length limit of e and, when e
+// collects violations, for noncharacters. Invalid UTF-8 is replaced first
+// when e is configured with WithInvalidUTF8Replacement.
+func (e *Encoder) appendString(dst []byte, s string) ([]byte, error) {
+	if e.replaceInvalidUTF8 && !utf8.ValidString(s) {
+		s = toValidUTF8(s)
+	}
+
+	if err := e.checkString(s); err != nil {
+		return dst, err
+	}
+
+	return appendString(dst, s)
+}
+
+// toValidUTF8 returns a copy of s in which every byte that is not part of a
+// valid UTF-8 sequence is replaced with U+FFFD, like encoding/json does.
+// Surrogate code points, which Go encodes as three invalid bytes, become
+// three replacement characters.
+func toValidUTF8(s string) string {
+	b := make([]byte, 0, len(s)+8)
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			b = utf8.AppendRune(b, utf8.RuneError)
+		} else {
+			b = append(b, s[i:i+size]...)
+		}
+		i += size
+	}
+
+	return string(b)
+}
+
 // appendString appends the canonical JSON representation of a Go string to dst.
 //
 // This function implements the string escaping and UTF-8 validation rules
@@ -65,26 +111,7 @@ func appendString(dst []byte, s string) ([]byte, error) {
 
 		// ASCII slow path (escaping)
 		if c < utf8.RuneSelf {
-			switch c {
-			case '"', '\\':
-				dst = append(dst, '\\', c)
-			case '\b':
-				dst = append(dst, '\\', 'b')
-			case '\t':
-				dst = append(dst, '\\', 't')
-			case '\n':
-				dst = append(dst, '\\', 'n')
-			case '\f':
-				dst = append(dst, '\\', 'f')
-			case '\r':
-				dst = append(dst, '\\', 'r')
-			default:
-				// control character → \u00XX
-				dst = append(dst, '\\', 'u', '0', '0',
-					hex[c>>4],
-					hex[c&0xF],
-				)
-			}
+			dst = appendEscapedASCII(dst, c)
 			i++
 			continue
 		}
@@ -113,3 +140,30 @@ func appendString(dst []byte, s string) ([]byte, error) {
 	dst = append(dst, '"')
 	return dst, nil
 }
+
+// appendEscapedASCII appends the canonical escape sequence of the ASCII
+// character c, which must be '"', '\\' or a control character (< U+0020).
+// RFC 8785 mandates the short forms \b, \t, \n, \f, \r, \" and \\ where
+// they exist and lowercase \u00XX for the remaining control characters.
+func appendEscapedASCII(dst []byte, c byte) []byte {
+	switch c {
+	case '"', '\\':
+		return append(dst, '\\', c)
+	case '\b':
+		return append(dst, '\\', 'b')
+	case '\t':
+		return append(dst, '\\', 't')
+	case '\n':
+		return append(dst, '\\', 'n')
+	case '\f':
+		return append(dst, '\\', 'f')
+	case '\r':
+		return append(dst, '\\', 'r')
+	}
+
+	// control character → \u00XX
+	return append(dst, '\\', 'u', '0', '0',
+		hex[c>>4],
+		hex[c&0xF],
+	)
+}
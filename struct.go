@@ -0,0 +1,419 @@
+package jcs
+
+import (
+	"cmp"
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// field describes a single JSON object member produced by a struct field,
+// resolved with the same rules as encoding/json.
+type field struct {
+	// name is the JSON member name, taken from the `json` tag or the Go
+	// field name.
+	name string
+
+	// key is the canonical encoding of name followed by ':', computed once
+	// so that encoding a struct does not re-escape its member names.
+	key []byte
+
+	// index is the path of field indices from the outer struct, as used by
+	// reflect.Value.FieldByIndex. Promoted fields have more than one index.
+	index []int
+
+	// typ is the field type, dereferenced once for unnamed pointer types.
+	typ reflect.Type
+
+	// tag reports whether name was given explicitly in the `json` tag.
+	tag bool
+
+	omitEmpty bool
+	omitZero  bool
+
+	// quoted reports the `string` option, which encodes a scalar value as
+	// a JSON string containing its canonical JSON form.
+	quoted bool
+}
+
+// fieldCache maps a struct reflect.Type to its []field in canonical order.
+var fieldCache sync.Map
+
+// cachedFields returns the encodable fields of struct type t, sorted by the
+// UTF-16 code units of their names as required by RFC 8785. Since the member
+// names of a struct are fixed, sorting happens once per type instead of once
+// per encoded value.
+func cachedFields(t reflect.Type) []field {
+	if f, ok := fieldCache.Load(t); ok {
+		return f.([]field)
+	}
+
+	f, _ := fieldCache.LoadOrStore(t, typeFields(t))
+	return f.([]field)
+}
+
+// typeFields returns the fields that encoding/json would encode for struct
+// type t. It walks embedded structs breadth-first so that shallower fields
+// hide deeper ones, and applies the encoding/json dominance rules when
+// several fields at the same depth share a name:
+//   - a field named by a `json` tag wins over untagged fields;
+//   - otherwise all fields with that name are dropped.
+//
+// Unexported fields, fields tagged `json:"-"` and unexported embedded
+// non-struct types are skipped.
+func typeFields(t reflect.Type) []field {
+	var (
+		current []field
+		next    = []field{{typ: t}}
+
+		count     map[reflect.Type]int
+		nextCount map[reflect.Type]int
+
+		visited = map[reflect.Type]bool{}
+		fields  []field
+	)
+
+	for len(next) > 0 {
+		current, next = next, current[:0]
+		count, nextCount = nextCount, map[reflect.Type]int{}
+
+		for _, f := range current {
+			if visited[f.typ] {
+				continue
+			}
+			visited[f.typ] = true
+
+			for i := 0; i < f.typ.NumField(); i++ {
+				sf := f.typ.Field(i)
+				if sf.Anonymous {
+					ft := sf.Type
+					if ft.Kind() == reflect.Pointer {
+						ft = ft.Elem()
+					}
+					if !sf.IsExported() && ft.Kind() != reflect.Struct {
+						continue
+					}
+				} else if !sf.IsExported() {
+					continue
+				}
+
+				tag := sf.Tag.Get("json")
+				if tag == "-" {
+					continue
+				}
+
+				name, opts, _ := strings.Cut(tag, ",")
+				if !isValidTag(name) {
+					name = ""
+				}
+
+				index := make([]int, len(f.index)+1)
+				copy(index, f.index)
+				index[len(f.index)] = i
+
+				ft := sf.Type
+				if ft.Name() == "" && ft.Kind() == reflect.Pointer {
+					ft = ft.Elem()
+				}
+
+				// record a field unless it is an untagged embedded struct,
+				// whose own fields are promoted on the next level instead.
+				if name != "" || !sf.Anonymous || ft.Kind() != reflect.Struct {
+					tagged := name != ""
+					if name == "" {
+						name = sf.Name
+					}
+
+					fields = append(fields, field{
+						name:      name,
+						index:     index,
+						typ:       ft,
+						tag:       tagged,
+						omitEmpty: hasOption(opts, "omitempty"),
+						omitZero:  hasOption(opts, "omitzero"),
+						quoted:    hasOption(opts, "string") && isQuotable(ft.Kind()),
+					})
+
+					// the same struct embedded several times at this level:
+					// duplicate the field so that the dominance check below
+					// annihilates it.
+					if count[f.typ] > 1 {
+						fields = append(fields, fields[len(fields)-1])
+					}
+					continue
+				}
+
+				nextCount[ft]++
+				if nextCount[ft] == 1 {
+					next = append(next, field{name: ft.Name(), index: index, typ: ft})
+				}
+			}
+		}
+	}
+
+	slices.SortFunc(fields, func(a, b field) int {
+		if c := strings.Compare(a.name, b.name); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(len(a.index), len(b.index)); c != 0 {
+			return c
+		}
+		if a.tag != b.tag {
+			if a.tag {
+				return -1
+			}
+			return 1
+		}
+		return slices.Compare(a.index, b.index)
+	})
+
+	// drop hidden and ambiguous fields, fields[i:i+n] share the same name
+	out := fields[:0]
+	for i, n := 0, 0; i < len(fields); i += n {
+		for n = 1; i+n < len(fields); n++ {
+			if fields[i+n].name != fields[i].name {
+				break
+			}
+		}
+
+		if n == 1 {
+			out = append(out, fields[i])
+			continue
+		}
+
+		dominant := fields[i : i+n]
+		if len(dominant[0].index) == len(dominant[1].index) && dominant[0].tag == dominant[1].tag {
+			continue
+		}
+		out = append(out, dominant[0])
+	}
+	fields = out
+
+	slices.SortFunc(fields, func(a, b field) int {
+		return compareUTF16(a.name, b.name)
+	})
+
+	for i := range fields {
+		// names are either Go identifiers or tags accepted by
+		// isValidTag, both of which are valid UTF-8.
+		key, _ := appendString(nil, fields[i].name)
+		fields[i].key = append(key, ':')
+	}
+
+	return fields
+}
+
+// appendStruct appends the canonical JSON representation of a struct value
+// to dst.
+//
+// The struct is encoded as a JSON object whose members are its exported
+// fields, named and filtered according to their `json` tags with the same
+// semantics as encoding/json:
+//
+//   - `json:"name"` renames the member; `json:"-"` skips the field.
+//   - `omitempty` skips false, 0, nil pointers and interfaces and empty
+//     strings, slices, maps and arrays.
+//   - `omitzero` skips zero values, using an IsZero() bool method when the
+//     field type provides one.
+//   - `string` encodes a bool, number or string field as a JSON string
+//     holding its canonical JSON representation, e.g. "1.5" for a
+//     json.Number; it is ignored for types with their own encoding, such
+//     as json.Marshaler implementations.
+//   - Fields of embedded structs are promoted into the outer object.
+//
+// Members are emitted in RFC 8785 order, i.e. sorted by the UTF-16 code
+// units of their names, which is the ordering appendObject applies to map
+// keys. Member values are encoded with Append.
+//
+// Error handling:
+//   - Returns any error produced by Append when encoding a field value, as
+//     an *EncodeError whose Path starts with the member name.
+//   - If an error occurs, no partial object is returned; dst is reset to its
+//     original state before appendStruct was called.
+func (e *Encoder) appendStruct(dst []byte, v reflect.Value) ([]byte, error) {
+	if err := e.enter(); err != nil {
+		return dst, err
+	}
+	defer e.leave()
+
+	dstLen := e.offset(dst)
+	dst = append(dst, '{')
+
+	fields := cachedFields(v.Type())
+
+	for i := range fields {
+		f := &fields[i]
+
+		fv, ok := fieldByIndex(v, f.index)
+		if !ok {
+			continue
+		}
+		if (f.omitEmpty && isEmptyValue(fv)) || (f.omitZero && isZeroValue(fv)) {
+			continue
+		}
+
+		var err error
+		if dst, err = e.flush(dst); err != nil {
+			return dst, err
+		}
+
+		start := e.offset(dst)
+		if start > dstLen+1 {
+			dst = append(dst, ',')
+		}
+
+		dst = append(dst, f.key...)
+
+		mark := len(e.violations)
+
+		if f.quoted {
+			dst, err = e.appendQuoted(dst, fv)
+		} else {
+			dst, err = e.appendValue(dst, fv)
+		}
+		if err != nil || len(e.violations) > mark {
+			if e.dropped(err) {
+				dst = e.truncate(dst, start)
+				continue
+			}
+			if err = e.memberError(err, mark, f.name, reflect.TypeOf(fv.Interface())); err != nil {
+				return e.truncate(dst, dstLen), err
+			}
+		}
+	}
+
+	dst = append(dst, '}')
+	return dst, nil
+}
+
+// appendQuoted implements the `string` tag option: the canonical JSON form
+// of the scalar v, encoded like any other value, e.g. "1.5" for a
+// json.Number "1.50", is itself encoded as a JSON string. Pointers are
+// followed and a nil pointer is encoded as null. Like encoding/json, the
+// option is ignored for types that provide their own encoding, such as
+// implementations of json.Marshaler or encoding.TextMarshaler.
+func (e *Encoder) appendQuoted(dst []byte, v reflect.Value) ([]byte, error) {
+	switch v.Interface().(type) {
+	case Marshaler, json.Marshaler, encoding.TextMarshaler:
+		return e.append(dst, v.Interface())
+	}
+	if v.CanAddr() && typeAddrMode(v.Type()) == addrMarshal {
+		return e.append(dst, v.Addr().Interface())
+	}
+
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return append(dst, 'n', 'u', 'l', 'l'), nil
+		}
+		v = v.Elem()
+	}
+
+	var scratch [64]byte
+	b, err := e.append(scratch[:0], v.Interface())
+	if err != nil {
+		return dst, err
+	}
+
+	return appendString(dst, string(b))
+}
+
+// fieldByIndex returns the nested field of v at index. It reports false
+// when the path goes through a nil embedded pointer, in which case the
+// field is absent from the encoded object.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+
+	return v, true
+}
+
+// isEmptyValue reports whether v is empty in the sense of the `omitempty`
+// option of encoding/json.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64,
+		reflect.Interface, reflect.Pointer:
+		return v.IsZero()
+	}
+
+	return false
+}
+
+// isZeroValue reports whether v is zero in the sense of the `omitzero`
+// option of encoding/json: an IsZero() bool method takes precedence over
+// reflect.Value.IsZero.
+func isZeroValue(v reflect.Value) bool {
+	if v.Kind() == reflect.Pointer && v.IsNil() {
+		return true
+	}
+
+	if z, ok := v.Interface().(interface{ IsZero() bool }); ok {
+		return z.IsZero()
+	}
+
+	return v.IsZero()
+}
+
+// isQuotable reports whether the `string` option applies to kind k.
+func isQuotable(k reflect.Kind) bool {
+	switch k {
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64,
+		reflect.String:
+		return true
+	}
+
+	return false
+}
+
+// hasOption reports whether the comma-separated tag options contain name.
+func hasOption(opts, name string) bool {
+	for opts != "" {
+		var opt string
+		opt, opts, _ = strings.Cut(opts, ",")
+		if opt == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isValidTag reports whether s can be used as a member name given in a
+// `json` tag. It accepts the same names as encoding/json; invalid names fall
+// back to the Go field name.
+func isValidTag(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	for _, c := range s {
+		switch {
+		case strings.ContainsRune("!#$%&()*+-./:;<=>?@[]^_{|}~ ", c):
+			// backslash and quote chars are reserved, but otherwise any
+			// punctuation chars are allowed in a tag name.
+		case !unicode.IsLetter(c) && !unicode.IsDigit(c):
+			return false
+		}
+	}
+
+	return true
+}
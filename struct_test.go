@@ -0,0 +1,184 @@
+package jcs
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type structInner struct {
+	B string `json:"b"`
+	A int    `json:"a"`
+}
+
+type structEmbedded struct {
+	Promoted string
+	Shadowed string
+}
+
+type structEmbeddedTagged struct {
+	X int `json:"x"`
+}
+
+type structHidden struct {
+	Hidden bool
+}
+
+type structOuter struct {
+	structEmbedded
+	*structHidden
+	Tagged     structEmbeddedTagged `json:"tagged"`
+	Shadowed   string
+	Name       string      `json:"name"`
+	Nested     structInner `json:"nested"`
+	Skipped    string      `json:"-"`
+	Dash       string      `json:"-,"`
+	Empty      string      `json:"empty,omitempty"`
+	Zero       time.Time   `json:"zero,omitzero"`
+	Quoted     int         `json:"quoted,string"`
+	QuotedStr  string      `json:"quoted_str,string"`
+	unexported string
+	Unicode    bool `json:"𝒜"`
+	Late       bool `json:"ﬁ"`
+}
+
+type structStatus string
+
+type structQuoted struct {
+	Number    json.Number           `json:"number,string"`
+	Pointer   *float64              `json:"pointer,string"`
+	Nil       *int                  `json:"nil,string"`
+	Marshaler structQuotedMarshaler `json:"marshaler,string"`
+	Text      marshalText           `json:"text,string"`
+}
+
+type structQuotedMarshaler int
+
+func (m structQuotedMarshaler) MarshalJSON() ([]byte, error) {
+	return []byte(`{ "m" : ` + strconv.Itoa(int(m)) + ` }`), nil
+}
+
+type structNamed struct {
+	Status structStatus `json:"status"`
+	Cents  int64        `json:"cents"`
+}
+
+type structAmbiguousA struct{ Dup int }
+type structAmbiguousB struct{ Dup int }
+
+type structAmbiguous struct {
+	structAmbiguousA
+	structAmbiguousB
+	Other int
+}
+
+func TestAppendStruct(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   any
+		want    string
+		wantErr error
+	}{
+		{name: "Empty", value: struct{}{}, want: `{}`},
+		{name: "SortedMembers", value: structInner{B: "x", A: 1}, want: `{"a":1,"b":"x"}`},
+		{
+			name: "TagsAndEmbedding",
+			value: structOuter{
+				structEmbedded: structEmbedded{Promoted: "p", Shadowed: "inner"},
+				Tagged:         structEmbeddedTagged{X: 1},
+				Shadowed:       "outer",
+				Name:           "n",
+				Nested:         structInner{B: "b", A: 2},
+				Skipped:        "skipped",
+				Dash:           "dash",
+				Quoted:         42,
+				QuotedStr:      "q",
+				unexported:     "unexported",
+				Unicode:        true,
+				Late:           true,
+			},
+			want: `{"-":"dash","Promoted":"p","Shadowed":"outer","name":"n","nested":{"a":2,"b":"b"},"quoted":"42","quoted_str":"\"q\"","tagged":{"x":1},"𝒜":true,"ﬁ":true}`,
+		},
+		{
+			name:  "QuotedOwnEncodings",
+			value: structQuoted{Number: "1.50", Pointer: new(float64), Marshaler: 7, Text: "x"},
+			want:  `{"marshaler":{"m":7},"nil":null,"number":"1.5","pointer":"0","text":"text:x"}`,
+		},
+		{name: "ErrQuotedNumber", value: structQuoted{Number: "1e400"}, wantErr: ErrNumberOOR},
+		{name: "NamedKinds", value: structNamed{Status: "ok", Cents: 12}, want: `{"cents":12,"status":"ok"}`},
+		{name: "AmbiguousFieldsDropped", value: structAmbiguous{Other: 1}, want: `{"Other":1}`},
+		{name: "ErrNumberOOR", value: structNamed{Cents: math.MaxInt64}, wantErr: ErrNumberOOR},
+		{name: "ErrNaN", value: struct{ F float64 }{math.NaN()}, wantErr: ErrNaN},
+		{name: "ErrUnsupportedType", value: struct{ F func() }{}, wantErr: ErrUnsupportedType},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			out, err := Append(nil, tc.value)
+			Equals(t, true, errors.Is(err, tc.wantErr))
+			Equals(t, tc.want, string(out))
+		})
+	}
+}
+
+// TestAppendStructMatchesObject checks that a struct and the map obtained by
+// round-tripping it through encoding/json canonicalize to the same bytes.
+func TestAppendStructMatchesObject(t *testing.T) {
+	v := structOuter{
+		structEmbedded: structEmbedded{Promoted: "p"},
+		Name:           "n",
+		Empty:          "e",
+		Zero:           time.Date(2019, 1, 28, 7, 45, 10, 0, time.UTC),
+		Unicode:        true,
+	}
+
+	b, err := json.Marshal(v)
+	Equals(t, nil, err)
+
+	var m map[string]any
+	Equals(t, nil, json.Unmarshal(b, &m))
+
+	want, err := Append(nil, m)
+	Equals(t, nil, err)
+
+	got, err := Append(nil, v)
+	Equals(t, nil, err)
+	Equals(t, string(want), string(got))
+}
+
+func TestTypeFieldsOrder(t *testing.T) {
+	fields := cachedFields(reflectTypeOf[structOuter]())
+
+	names := make([]string, len(fields))
+	for i, f := range fields {
+		names[i] = f.name
+	}
+
+	Equals(t, true, sort.SliceIsSorted(names, func(i, j int) bool {
+		return compareUTF16(names[i], names[j]) < 0
+	}))
+}
+
+func BenchmarkAppendStruct(b *testing.B) {
+	b.ReportAllocs()
+
+	v := structOuter{
+		structEmbedded: structEmbedded{Promoted: "p", Shadowed: "inner"},
+		Name:           "name",
+		Nested:         structInner{B: "b", A: 2},
+		Quoted:         42,
+	}
+	buf := make([]byte, 0, 1024)
+
+	for b.Loop() {
+		_, err := Append(buf[:0], v)
+		if err != nil {
+			b.Fatal(err)
+			return
+		}
+	}
+}
@@ -1,50 +1,145 @@
 package jcs
 
 import (
-	"bytes"
 	"time"
 )
 
+// TimeFormat selects how an Encoder writes time.Time values, see
+// WithTimeFormat. RFC 8785 has no notion of time: every format produces an
+// ordinary JSON string or number, so producer and verifier must agree on it.
+type TimeFormat int
+
+const (
+	// TimeRFC3339 writes the time in UTC as an RFC 3339 string with the
+	// fractional seconds trimmed of trailing zeros, such as
+	// "2019-01-28T07:45:10.5Z". This is the default.
+	TimeRFC3339 TimeFormat = iota
+
+	// TimeRFC3339Millis writes the time in UTC as an RFC 3339 string with
+	// exactly three fractional digits, such as "2019-01-28T07:45:10.500Z".
+	// Smaller units are truncated.
+	TimeRFC3339Millis
+
+	// TimeRFC3339Seconds writes the time in UTC as an RFC 3339 string
+	// without fractional seconds, such as "2019-01-28T07:45:10Z".
+	// Fractions of a second are truncated.
+	TimeRFC3339Seconds
+
+	// TimeRFC3339Offset writes the time as an RFC 3339 string like
+	// TimeRFC3339, but keeps its original UTC offset, such as
+	// "2019-01-28T08:45:10.5+01:00". The name of the location is lost.
+	TimeRFC3339Offset
+
+	// TimeUnix writes the time as a JSON number of seconds since the Unix
+	// epoch, such as 1548661510. Fractions of a second are truncated.
+	TimeUnix
+
+	// TimeUnixMilli writes the time as a JSON number of milliseconds since
+	// the Unix epoch, such as 1548661510500. Smaller units are truncated.
+	TimeUnixMilli
+)
+
+// WithTimeFormat makes the encoder write time.Time and *time.Time values in
+// format f instead of TimeRFC3339.
+func WithTimeFormat(f TimeFormat) Option {
+	return func(e *Encoder) {
+		e.timeFormat = f
+	}
+}
+
+// WithZeroTimeAsNull makes the encoder write the zero time.Time, for which
+// IsZero reports true, as null instead of "0001-01-01T00:00:00Z", so that
+// an unset time is encoded like a nil *time.Time.
+func WithZeroTimeAsNull() Option {
+	return func(e *Encoder) {
+		e.zeroTimeAsNull = true
+	}
+}
+
+// DurationFormat selects how an Encoder writes time.Duration values, see
+// WithDurationFormat.
+type DurationFormat int
+
+const (
+	// DurationNanoseconds writes a duration as a JSON number of
+	// nanoseconds, such as 1500000000, like any int64. Durations beyond
+	// ±MaxSafeNumber nanoseconds (about 104 days) fail with ErrNumberOOR.
+	// This is the default.
+	DurationNanoseconds DurationFormat = iota
+
+	// DurationString writes a duration as a string in the format of
+	// Duration.String, such as "1m30.5s".
+	DurationString
+
+	// DurationSeconds writes a duration as a JSON number of seconds, such
+	// as 1.5, converted with Duration.Seconds.
+	DurationSeconds
+)
+
+// WithDurationFormat makes the encoder write time.Duration values in format
+// f instead of DurationNanoseconds.
+func WithDurationFormat(f DurationFormat) Option {
+	return func(e *Encoder) {
+		e.durationFormat = f
+	}
+}
+
+// appendDuration appends d in the duration format of e.
+func (e *Encoder) appendDuration(dst []byte, d time.Duration) ([]byte, error) {
+	switch e.durationFormat {
+	case DurationString:
+		return e.appendString(dst, d.String())
+
+	case DurationSeconds:
+		return appendNumber(dst, d.Seconds())
+	}
+
+	return e.append(dst, int64(d))
+}
+
+// appendTime appends t in the time format of e. A nil t is encoded as null.
+func (e *Encoder) appendTime(dst []byte, t *time.Time) ([]byte, error) {
+	if t == nil || e.zeroTimeAsNull && t.IsZero() {
+		return append(dst, 'n', 'u', 'l', 'l'), nil
+	}
+
+	switch e.timeFormat {
+	case TimeRFC3339Millis:
+		return appendTimeLayout(dst, t.UTC(), "2006-01-02T15:04:05.000Z07:00"), nil
+
+	case TimeRFC3339Seconds:
+		return appendTimeLayout(dst, t.UTC(), time.RFC3339), nil
+
+	case TimeRFC3339Offset:
+		return appendTimeLayout(dst, *t, time.RFC3339Nano), nil
+
+	case TimeUnix:
+		return e.append(dst, t.Unix())
+
+	case TimeUnixMilli:
+		return e.append(dst, t.UnixMilli())
+	}
+
+	return appendTime(dst, *t), nil
+}
+
 // appendTime appends a time.Time value to dst as a JSON string.
 //
 // The time is first converted to UTC and formatted using RFC3339Nano,
 // producing a deterministic, canonical representation such as
 // "2019-01-28T07:45:10Z". Per RFC 8785 (JSON Canonicalization Scheme),
 // time values are treated as ordinary JSON strings with no special
-// normalization beyond consistent formatting. The resulting string is
-// then quoted and escaped using appendString to ensure valid JSON.
+// normalization beyond consistent formatting. RFC3339Nano trims trailing
+// zeros from the fractional seconds, and the dot with them, and writes the
+// UTC offset as "Z".
 func appendTime(dst []byte, t time.Time) []byte {
-	// normalize to UTC
-	t = t.UTC()
-
-	// Format with nanosecond
-	dst = append(dst, '"')
-	dst = t.AppendFormat(dst, time.RFC3339Nano)
-
-	// Trim trailing zeros in fractional seconds if present
-	if i := bytes.IndexByte(dst, '.'); i != -1 {
-		// find end of fractional part before 'Z' or '+'
-		end := len(dst) - 1
-		for j := end - 1; j > i; j-- {
-			if dst[j] == '0' {
-				end--
-			} else {
-				break
-			}
-		}
-		// if we trimmed all fractional digits, also trim the '.'
-		if dst[end-1] == '.' {
-			end--
-		}
-		dst = dst[:end+1] // keep suffix (Z or +00:00)
-	}
-
-	// Replace "+00:00" with "Z" for UTC
-	if bytes.HasSuffix(dst, []byte("+00:00")) {
-		dst = append(dst[:len(dst)-6], 'Z')
-	}
+	return appendTimeLayout(dst, t.UTC(), time.RFC3339Nano)
+}
 
+// appendTimeLayout appends t formatted with layout as a JSON string. The
+// layouts used here never produce characters that need escaping.
+func appendTimeLayout(dst []byte, t time.Time, layout string) []byte {
 	dst = append(dst, '"')
-
-	return dst
+	dst = t.AppendFormat(dst, layout)
+	return append(dst, '"')
 }
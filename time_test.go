@@ -23,6 +23,11 @@ func TestAppendTime(t *testing.T) {
 			in:   time.Date(2019, 1, 28, 7, 45, 10, 123456000, time.UTC),
 			want: `"2019-01-28T07:45:10.123456Z"`,
 		},
+		{
+			name: "2019-01-28T07:45:10.1Z",
+			in:   time.Date(2019, 1, 28, 8, 45, 10, 100000000, time.FixedZone("CET", 3600)),
+			want: `"2019-01-28T07:45:10.1Z"`,
+		},
 	}
 
 	for _, tc := range tests {
@@ -33,6 +38,104 @@ func TestAppendTime(t *testing.T) {
 	}
 }
 
+func TestAppendTimeAfterNumber(t *testing.T) {
+	// the fraction of a preceding number is not taken for that of the time
+	in := time.Date(2019, 1, 28, 7, 45, 10, 0, time.UTC)
+
+	got, err := Append(nil, []any{1.5, in})
+	Equals(t, nil, err)
+	Equals(t, `[1.5,"2019-01-28T07:45:10Z"]`, string(got))
+}
+
+type timeRecord struct {
+	At      time.Time  `json:"at"`
+	Expires *time.Time `json:"expires"`
+}
+
+// timeStamp embeds time.Time, whose MarshalJSON is promoted.
+type timeStamp struct{ time.Time }
+
+func TestEncoderTimeFormat(t *testing.T) {
+	cet := time.FixedZone("CET", 3600)
+	in := time.Date(2019, 1, 28, 8, 45, 10, 123456789, cet)
+	whole := time.Date(2019, 1, 28, 7, 45, 10, 0, time.UTC)
+
+	testCases := []struct {
+		name  string
+		opts  []Option
+		value any
+		want  string
+	}{
+		{name: "Default", value: in, want: `"2019-01-28T07:45:10.123456789Z"`},
+		{name: "RFC3339", opts: []Option{WithTimeFormat(TimeRFC3339)}, value: whole, want: `"2019-01-28T07:45:10Z"`},
+		{name: "Millis", opts: []Option{WithTimeFormat(TimeRFC3339Millis)}, value: in, want: `"2019-01-28T07:45:10.123Z"`},
+		{name: "MillisWhole", opts: []Option{WithTimeFormat(TimeRFC3339Millis)}, value: whole, want: `"2019-01-28T07:45:10.000Z"`},
+		{name: "Seconds", opts: []Option{WithTimeFormat(TimeRFC3339Seconds)}, value: in, want: `"2019-01-28T07:45:10Z"`},
+		{name: "Offset", opts: []Option{WithTimeFormat(TimeRFC3339Offset)}, value: in, want: `"2019-01-28T08:45:10.123456789+01:00"`},
+		{name: "OffsetUTC", opts: []Option{WithTimeFormat(TimeRFC3339Offset)}, value: whole, want: `"2019-01-28T07:45:10Z"`},
+		{name: "Unix", opts: []Option{WithTimeFormat(TimeUnix)}, value: in, want: `1548661510`},
+		{name: "UnixMilli", opts: []Option{WithTimeFormat(TimeUnixMilli)}, value: in, want: `1548661510123`},
+		{name: "UnixBeforeEpoch", opts: []Option{WithTimeFormat(TimeUnix)}, value: time.Date(1969, 12, 31, 23, 59, 59, 0, time.UTC), want: `-1`},
+
+		{name: "Pointer", opts: []Option{WithTimeFormat(TimeUnix)}, value: &whole, want: `1548661510`},
+		{name: "PointerDefault", value: &in, want: `"2019-01-28T07:45:10.123456789Z"`},
+		{name: "PointerNil", value: (*time.Time)(nil), want: `null`},
+
+		{name: "Zero", value: time.Time{}, want: `"0001-01-01T00:00:00Z"`},
+		{name: "ZeroAsNull", opts: []Option{WithZeroTimeAsNull()}, value: time.Time{}, want: `null`},
+		{name: "ZeroAsNullUnix", opts: []Option{WithZeroTimeAsNull(), WithTimeFormat(TimeUnix)}, value: []time.Time{{}, whole}, want: `[null,1548661510]`},
+
+		{name: "Struct", opts: []Option{WithTimeFormat(TimeRFC3339Millis), WithZeroTimeAsNull()}, value: timeRecord{At: in}, want: `{"at":"2019-01-28T07:45:10.123Z","expires":null}`},
+		{name: "StructPointer", opts: []Option{WithTimeFormat(TimeUnixMilli)}, value: timeRecord{At: whole, Expires: &in}, want: `{"at":1548661510000,"expires":1548661510123}`},
+		{name: "Map", opts: []Option{WithTimeFormat(TimeRFC3339Offset)}, value: map[string]*time.Time{"t": &in}, want: `{"t":"2019-01-28T08:45:10.123456789+01:00"}`},
+
+		// embedding time.Time promotes its MarshalJSON, which ignores the
+		// time options and keeps the offset
+		{name: "Embedded", opts: []Option{WithTimeFormat(TimeUnix)}, value: timeStamp{in}, want: `"2019-01-28T08:45:10.123456789+01:00"`},
+		{name: "EmbeddedZero", opts: []Option{WithZeroTimeAsNull()}, value: timeStamp{}, want: `"0001-01-01T00:00:00Z"`},
+		{name: "EmbeddedField", opts: []Option{WithTimeFormat(TimeUnix)}, value: timeStamp{in}.Time, want: `1548661510`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := NewEncoder(tc.opts...).Append(nil, tc.value)
+			Equals(t, nil, err)
+			Equals(t, tc.want, string(got))
+		})
+	}
+}
+
+func TestEncoderDurationFormat(t *testing.T) {
+	d := time.Minute + 30*time.Second + 500*time.Millisecond
+
+	testCases := []struct {
+		name    string
+		opts    []Option
+		value   any
+		want    string
+		wantErr error
+	}{
+		{name: "Default", value: d, want: `90500000000`},
+		{name: "DefaultOOR", value: 200 * 24 * time.Hour, wantErr: ErrNumberOOR},
+		{name: "String", opts: []Option{WithDurationFormat(DurationString)}, value: d, want: `"1m30.5s"`},
+		{name: "StringZero", opts: []Option{WithDurationFormat(DurationString)}, value: time.Duration(0), want: `"0s"`},
+		{name: "StringNegative", opts: []Option{WithDurationFormat(DurationString)}, value: -time.Millisecond, want: `"-1ms"`},
+		{name: "Seconds", opts: []Option{WithDurationFormat(DurationSeconds)}, value: d, want: `90.5`},
+		{name: "SecondsLong", opts: []Option{WithDurationFormat(DurationSeconds)}, value: 200 * 24 * time.Hour, want: `17280000`},
+		{name: "SecondsSmall", opts: []Option{WithDurationFormat(DurationSeconds)}, value: time.Nanosecond, want: `1e-9`},
+		{name: "Pointer", opts: []Option{WithDurationFormat(DurationString)}, value: &d, want: `"1m30.5s"`},
+		{name: "Struct", opts: []Option{WithDurationFormat(DurationString)}, value: struct{ TTL time.Duration }{time.Hour}, want: `{"TTL":"1h0m0s"}`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := NewEncoder(tc.opts...).Append(nil, tc.value)
+			Equals(t, tc.wantErr, err)
+			Equals(t, tc.want, string(got))
+		})
+	}
+}
+
 func BenchmarkAppendTime(b *testing.B) {
 	b.ReportAllocs()
 
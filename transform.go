@@ -0,0 +1,652 @@
+package jcs
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// maxNestingDepth bounds the nesting of arrays and objects accepted by
+// Transform, protecting its recursive parser against stack exhaustion on
+// hostile input. It matches the limit of encoding/json.
+const maxNestingDepth = 10000
+
+// Transform parses the JSON text src and appends its canonical form, as
+// defined by RFC 8785, to dst.
+//
+// Unlike decoding src into an `any` and calling Append, Transform works
+// directly on the bytes with its own strict tokenizer:
+//
+//   - Numbers are converted from their literal, so integers and other
+//     literals that an IEEE‑754 double would round, like 9007199254740993,
+//     are reported (ErrNumberOOR, ErrNumberPrecision) instead of being
+//     rounded silently. Integers a double holds exactly, like 1e20 written
+//     out in full, are accepted.
+//   - Objects with duplicate member names are rejected with ErrDuplicateKey.
+//   - Strings must be valid UTF-8, and \u escapes must not encode lone
+//     surrogates (ErrInvalidUTF8).
+//   - Insignificant whitespace is removed, object members are sorted by
+//     their UTF-16 code units, strings are re-escaped and numbers are
+//     re-serialized exactly like Append does.
+//
+// Transform does not build an intermediate tree: values are written to dst
+// as they are parsed and object members are reordered in place, so the
+// only allocations are scratch buffers reused across calls.
+//
+// Transform only bounds nesting, at 10000 levels. Use Encoder.Transform
+// or Encoder.TransformContext to apply the limits of an Encoder, such as
+// WithMaxDepth or WithMaxStringLength, and a context, to untrusted input.
+//
+// Error handling:
+//   - Every error is a *SyntaxError carrying the byte offset, line and
+//     column at which it was detected; canonicalization failures wrap the
+//     matching sentinel error, which can be tested with errors.Is.
+//   - If an error occurs, dst is returned unchanged.
+//
+// Example:
+//
+//	out, err := jcs.Transform(nil, []byte(`{ "b": 1.50, "a": [true, null] }`))
+//	fmt.Println(string(out))
+//	// Output: {"a":[true,null],"b":1.5}
+func Transform(dst, src []byte) ([]byte, error) {
+	return transformWith(dst, src, nil)
+}
+
+// transformWith implements Transform, within the limits and context of e
+// unless e is nil.
+func transformWith(dst, src []byte, e *Encoder) ([]byte, error) {
+	t := transformerPool.Get().(*transformer)
+	defer t.release()
+
+	t.src, t.e = src, e
+	if e != nil {
+		t.depth = e.depth
+	}
+	dstLen := len(dst)
+
+	dst, err := t.transform(dst)
+	if err != nil {
+		return dst[:dstLen], err
+	}
+
+	return dst, nil
+}
+
+// transformerPool holds transformers whose scratch buffers are reused by
+// subsequent calls to Transform.
+var transformerPool = sync.Pool{
+	New: func() any { return new(transformer) },
+}
+
+// member records where an object member was written while parsing, so that
+// the members can be reordered once the whole object has been read.
+type member struct {
+	// start and end delimit the `"name":value` bytes of the member in dst.
+	start, end int
+
+	// offset is the position of the member name in the source, used to
+	// report duplicate names.
+	offset int
+}
+
+// transformer is the state of a single Transform call.
+//
+// The scratch slices are used as stacks: every object pushes its keys and
+// members on top of those of its enclosing objects and truncates them back
+// when it ends, so one set of buffers serves any nesting depth.
+type transformer struct {
+	src   []byte
+	pos   int
+	depth int
+
+	// verify makes the transformer report the first deviation of src from
+	// its canonical form instead of rewriting it, see Verify.
+	verify bool
+
+	// e holds the limits and context that apply, or is nil for the
+	// defaults of Transform.
+	e *Encoder
+
+	// utf16 holds the UTF-16 code units of the member names of the objects
+	// being parsed, indexed by keys.
+	utf16 []uint16
+	keys  []kv
+
+	// members holds the output spans of object members, indexed by kv.idx.
+	members []member
+
+	// scratch is the copy of an object's members used while reordering.
+	scratch []byte
+}
+
+// release clears the transformer and returns it to transformerPool.
+func (t *transformer) release() {
+	*t = transformer{
+		utf16:   t.utf16[:0],
+		keys:    t.keys[:0],
+		members: t.members[:0],
+		scratch: t.scratch[:0],
+	}
+	transformerPool.Put(t)
+}
+
+// transform parses exactly one JSON value surrounded by optional whitespace.
+func (t *transformer) transform(dst []byte) ([]byte, error) {
+	if err := t.skipSpace(); err != nil {
+		return dst, err
+	}
+
+	dst, err := t.value(dst)
+	if err != nil {
+		return dst, err
+	}
+
+	if err := t.skipSpace(); err != nil {
+		return dst, err
+	}
+	if t.pos < len(t.src) {
+		return dst, t.errorf("invalid character %s after top-level value", quoteChar(t.src[t.pos]))
+	}
+
+	if t.e != nil {
+		if err := t.e.checkOutputSize(dst); err != nil {
+			return dst, newSyntaxError(t.src, t.pos, err, "")
+		}
+	}
+
+	return dst, nil
+}
+
+// value parses the JSON value starting at t.pos and appends its canonical
+// form to dst.
+func (t *transformer) value(dst []byte) ([]byte, error) {
+	if t.pos >= len(t.src) {
+		return dst, t.errorf("unexpected end of JSON input")
+	}
+
+	if t.e != nil && (t.e.ctx != nil || t.e.maxOutputSize > 0) {
+		if err := t.e.step(dst); err != nil {
+			return dst, newSyntaxError(t.src, t.pos, err, "")
+		}
+	}
+
+	switch c := t.src[t.pos]; {
+	case c == '{':
+		return t.object(dst)
+	case c == '[':
+		return t.array(dst)
+	case c == '"':
+		return t.string(dst, false)
+	case c == '-' || (c >= '0' && c <= '9'):
+		return t.number(dst)
+	case c == 't':
+		return t.literal(dst, "true")
+	case c == 'f':
+		return t.literal(dst, "false")
+	case c == 'n':
+		return t.literal(dst, "null")
+	default:
+		return dst, t.errorf("invalid character %s looking for beginning of value", quoteChar(c))
+	}
+}
+
+// object parses a JSON object, sorts its members by the UTF-16 code units of
+// their names and rejects duplicate names.
+//
+// Members are first written to dst in source order. Once the closing brace
+// has been read, the member spans are sorted with sortKeys, the same
+// comparator used by appendObject, and copied back into place.
+func (t *transformer) object(dst []byte) ([]byte, error) {
+	if err := t.enter(); err != nil {
+		return dst, err
+	}
+
+	start := len(dst)
+	dst = append(dst, '{')
+	t.pos++
+
+	if err := t.skipSpace(); err != nil {
+		return dst, err
+	}
+	if t.pos < len(t.src) && t.src[t.pos] == '}' {
+		t.pos++
+		t.depth--
+		return append(dst, '}'), nil
+	}
+
+	keyBase, memberBase, utf16Base := len(t.keys), len(t.members), len(t.utf16)
+
+	for {
+		if err := t.skipSpace(); err != nil {
+			return dst, err
+		}
+		if t.pos >= len(t.src) || t.src[t.pos] != '"' {
+			return dst, t.unexpected("looking for beginning of object member name")
+		}
+
+		m := member{start: len(dst), offset: t.pos}
+		units := len(t.utf16)
+
+		var err error
+		if dst, err = t.string(dst, true); err != nil {
+			return dst, err
+		}
+		t.keys = append(t.keys, kv{start: units, len: len(t.utf16) - units, idx: len(t.members)})
+
+		// canonical input has its members already sorted, so each name
+		// must sort strictly after the previous one
+		if t.verify && len(t.keys)-keyBase > 1 {
+			if err := t.checkOrder(t.keys[len(t.keys)-2], t.keys[len(t.keys)-1], m.offset); err != nil {
+				return dst, err
+			}
+		}
+
+		if err := t.skipSpace(); err != nil {
+			return dst, err
+		}
+		if t.pos >= len(t.src) || t.src[t.pos] != ':' {
+			return dst, t.unexpected("after object member name")
+		}
+		dst = append(dst, ':')
+		t.pos++
+
+		if err := t.skipSpace(); err != nil {
+			return dst, err
+		}
+		if dst, err = t.value(dst); err != nil {
+			return dst, err
+		}
+		m.end = len(dst)
+		t.members = append(t.members, m)
+		if t.e != nil {
+			if err := t.e.checkMembers(len(t.members) - memberBase); err != nil {
+				return dst, newSyntaxError(t.src, m.offset, err, "")
+			}
+		}
+		if t.verify {
+			dst = dst[:start+1]
+		}
+
+		if err := t.skipSpace(); err != nil {
+			return dst, err
+		}
+		if t.pos < len(t.src) && t.src[t.pos] == ',' {
+			dst = append(dst, ',')
+			t.pos++
+			continue
+		}
+		if t.pos < len(t.src) && t.src[t.pos] == '}' {
+			t.pos++
+			break
+		}
+		return dst, t.unexpected("after object member value")
+	}
+
+	if t.verify {
+		// order was checked while reading, nothing to write
+		dst = dst[:start+1]
+	} else if keys := t.keys[keyBase:]; len(keys) > 1 {
+		sortKeys(keys, t.utf16)
+
+		for i := 1; i < len(keys); i++ {
+			a := t.utf16[keys[i-1].start : keys[i-1].start+keys[i-1].len]
+			b := t.utf16[keys[i].start : keys[i].start+keys[i].len]
+			if slices.Equal(a, b) {
+				offset := max(t.members[keys[i-1].idx].offset, t.members[keys[i].idx].offset)
+				return dst, newSyntaxError(t.src, offset, ErrDuplicateKey, "")
+			}
+		}
+
+		// reorder the members, t.scratch[0] is the byte after '{'
+		base := start + 1
+		t.scratch = append(t.scratch[:0], dst[base:]...)
+		dst = dst[:base]
+		for i, k := range keys {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			m := t.members[k.idx]
+			dst = append(dst, t.scratch[m.start-base:m.end-base]...)
+		}
+	}
+
+	t.keys = t.keys[:keyBase]
+	t.members = t.members[:memberBase]
+	t.utf16 = t.utf16[:utf16Base]
+	t.depth--
+
+	return append(dst, '}'), nil
+}
+
+// array parses a JSON array. Elements keep their order.
+func (t *transformer) array(dst []byte) ([]byte, error) {
+	if err := t.enter(); err != nil {
+		return dst, err
+	}
+
+	start := len(dst)
+	dst = append(dst, '[')
+	t.pos++
+
+	if err := t.skipSpace(); err != nil {
+		return dst, err
+	}
+	if t.pos < len(t.src) && t.src[t.pos] == ']' {
+		t.pos++
+		t.depth--
+		return append(dst, ']'), nil
+	}
+
+	for {
+		if err := t.skipSpace(); err != nil {
+			return dst, err
+		}
+
+		var err error
+		if dst, err = t.value(dst); err != nil {
+			return dst, err
+		}
+		if t.verify {
+			dst = dst[:start+1]
+		}
+
+		if err := t.skipSpace(); err != nil {
+			return dst, err
+		}
+		if t.pos < len(t.src) && t.src[t.pos] == ',' {
+			dst = append(dst, ',')
+			t.pos++
+			continue
+		}
+		if t.pos < len(t.src) && t.src[t.pos] == ']' {
+			t.pos++
+			break
+		}
+		return dst, t.unexpected("after array element")
+	}
+
+	t.depth--
+	return append(dst, ']'), nil
+}
+
+// string parses a JSON string and appends it re-escaped in canonical form:
+// escape sequences are decoded, and only '"', '\' and control characters
+// are escaped again, using the forms of appendString. If key is set, the
+// UTF-16 code units of the decoded string are pushed onto t.utf16.
+func (t *transformer) string(dst []byte, key bool) ([]byte, error) {
+	start := t.pos
+	dst = append(dst, '"')
+	t.pos++
+
+	// n is the length of the decoded string
+	n := 0
+
+	for {
+		if t.pos >= len(t.src) {
+			return dst, t.errorf("unexpected end of JSON input in string")
+		}
+
+		c := t.src[t.pos]
+		switch {
+		case c == '"':
+			if t.e != nil && t.e.maxStringLength > 0 && n > t.e.maxStringLength {
+				return dst, newSyntaxError(t.src, start, ErrMaxStringLength, "")
+			}
+			t.pos++
+			return append(dst, '"'), nil
+
+		case c == '\\':
+			escStart, outStart := t.pos, len(dst)
+			r, err := t.escape()
+			if err != nil {
+				return dst, err
+			}
+			if r < utf8.RuneSelf && (r < 0x20 || r == '"' || r == '\\') {
+				dst = appendEscapedASCII(dst, byte(r))
+				if t.verify && string(dst[outStart:]) != string(t.src[escStart:t.pos]) {
+					return dst, t.deviation(escStart, "escape sequence must be "+string(dst[outStart:]))
+				}
+			} else {
+				if t.verify {
+					return dst, t.deviation(escStart, "unnecessary escape sequence")
+				}
+				dst = utf8.AppendRune(dst, r)
+			}
+			if key {
+				t.pushRune(r)
+			}
+			n += utf8.RuneLen(r)
+
+		case c < 0x20:
+			return dst, t.errorf("invalid character %s in string literal", quoteChar(c))
+
+		case c < utf8.RuneSelf:
+			// copy contiguous safe ASCII
+			i := t.pos + 1
+			for i < len(t.src) && t.src[i] < utf8.RuneSelf && t.src[i] >= 0x20 && t.src[i] != '"' && t.src[i] != '\\' {
+				i++
+			}
+			if key {
+				for _, c := range t.src[t.pos:i] {
+					t.utf16 = append(t.utf16, uint16(c))
+				}
+			}
+			dst = append(dst, t.src[t.pos:i]...)
+			n += i - t.pos
+			t.pos = i
+
+		default:
+			// utf8.DecodeRune rejects invalid and truncated sequences as
+			// well as encoded surrogates with size 1; a literal U+FFFD has
+			// size 3.
+			r, size := utf8.DecodeRune(t.src[t.pos:])
+			if r == utf8.RuneError && size == 1 {
+				return dst, newSyntaxError(t.src, t.pos, ErrInvalidUTF8, "")
+			}
+			if key {
+				t.pushRune(r)
+			}
+			dst = append(dst, t.src[t.pos:t.pos+size]...)
+			n += size
+			t.pos += size
+		}
+	}
+}
+
+// escape decodes the escape sequence at t.pos. A \u escape of a high
+// surrogate must be directly followed by a \u escape of a low surrogate;
+// lone surrogates are rejected with ErrInvalidUTF8.
+func (t *transformer) escape() (rune, error) {
+	start := t.pos
+	if t.pos+1 >= len(t.src) {
+		return 0, t.errorf("unexpected end of JSON input in string escape")
+	}
+
+	c := t.src[t.pos+1]
+	t.pos += 2
+
+	switch c {
+	case '"', '\\', '/':
+		return rune(c), nil
+	case 'b':
+		return '\b', nil
+	case 'f':
+		return '\f', nil
+	case 'n':
+		return '\n', nil
+	case 'r':
+		return '\r', nil
+	case 't':
+		return '\t', nil
+	case 'u':
+		r, ok := hex4(t.src[t.pos:])
+		if !ok {
+			return 0, newSyntaxError(t.src, start, nil, "invalid \\u escape in string literal")
+		}
+		t.pos += 4
+
+		if !utf16.IsSurrogate(r) {
+			return r, nil
+		}
+
+		if r < 0xDC00 && t.pos+1 < len(t.src) && t.src[t.pos] == '\\' && t.src[t.pos+1] == 'u' {
+			if r2, ok := hex4(t.src[t.pos+2:]); ok && r2 >= 0xDC00 && r2 <= 0xDFFF {
+				t.pos += 6
+				return utf16.DecodeRune(r, r2), nil
+			}
+		}
+
+		return 0, newSyntaxError(t.src, start, ErrInvalidUTF8, "lone surrogate in \\u escape")
+	}
+
+	return 0, newSyntaxError(t.src, start, nil, fmt.Sprintf("invalid escape %s in string literal", quoteChar(c)))
+}
+
+// number parses a JSON number and appends it re-serialized with
+// appendNumberLiteral, i.e. exactly like Append serializes a json.Number.
+func (t *transformer) number(dst []byte) ([]byte, error) {
+	start := t.pos
+	for t.pos < len(t.src) {
+		c := t.src[t.pos]
+		if (c < '0' || c > '9') && c != '-' && c != '+' && c != '.' && c != 'e' && c != 'E' {
+			break
+		}
+		t.pos++
+	}
+
+	outStart := len(dst)
+	dst, err := appendNumberLiteral(dst, string(t.src[start:t.pos]))
+	if err != nil {
+		return dst, newSyntaxError(t.src, start, err, "")
+	}
+
+	if t.verify && string(dst[outStart:]) != string(t.src[start:t.pos]) {
+		return dst, t.deviation(start, "number must be "+string(dst[outStart:]))
+	}
+
+	return dst, nil
+}
+
+// literal parses one of the literal names true, false or null.
+func (t *transformer) literal(dst []byte, name string) ([]byte, error) {
+	if len(t.src)-t.pos < len(name) || string(t.src[t.pos:t.pos+len(name)]) != name {
+		return dst, t.errorf("invalid literal, expected %q", name)
+	}
+
+	t.pos += len(name)
+	return append(dst, name...), nil
+}
+
+// enter increments the nesting depth, failing beyond maxNestingDepth or
+// the depth limit of t.e.
+func (t *transformer) enter() error {
+	t.depth++
+	if t.depth > maxNestingDepth {
+		return t.errorf("exceeded max nesting depth of %d", maxNestingDepth)
+	}
+	if t.e != nil && t.e.maxDepth > 0 && t.depth > t.e.maxDepth {
+		return newSyntaxError(t.src, t.pos, ErrMaxDepth, "")
+	}
+	return nil
+}
+
+// skipSpace skips the insignificant whitespace allowed by RFC 8259. Canonical
+// JSON has none, so in verify mode any whitespace is a deviation.
+func (t *transformer) skipSpace() error {
+	start := t.pos
+	for t.pos < len(t.src) {
+		switch t.src[t.pos] {
+		case ' ', '\t', '\n', '\r':
+			t.pos++
+			continue
+		}
+		break
+	}
+
+	if t.verify && t.pos > start {
+		return t.deviation(start, "insignificant whitespace")
+	}
+	return nil
+}
+
+// pushRune appends the UTF-16 code units of r to t.utf16.
+func (t *transformer) pushRune(r rune) {
+	h, l := utf16Units(r)
+	t.utf16 = append(t.utf16, h)
+	if r >= 0x10_000 {
+		t.utf16 = append(t.utf16, l)
+	}
+}
+
+// checkOrder reports whether member name b, found at offset, sorts strictly
+// after the preceding member name a as canonical JSON requires.
+func (t *transformer) checkOrder(a, b kv, offset int) error {
+	c := slices.Compare(t.utf16[a.start:a.start+a.len], t.utf16[b.start:b.start+b.len])
+	switch {
+	case c == 0:
+		return newSyntaxError(t.src, offset, ErrDuplicateKey, "")
+	case c > 0:
+		return t.deviation(offset, "object member names are not sorted")
+	}
+	return nil
+}
+
+// deviation returns the error reported by Verify for a construct at offset
+// that is valid JSON but not in canonical form.
+func (t *transformer) deviation(offset int, reason string) error {
+	return newSyntaxError(t.src, offset, ErrNotCanonical, "not canonical, "+reason)
+}
+
+// errorf returns a plain syntax error at the current position.
+func (t *transformer) errorf(format string, args ...any) error {
+	return newSyntaxError(t.src, t.pos, nil, fmt.Sprintf(format, args...))
+}
+
+// unexpected reports the character at the current position, or the end of
+// the input, as unexpected in the given context.
+func (t *transformer) unexpected(context string) error {
+	if t.pos >= len(t.src) {
+		return t.errorf("unexpected end of JSON input")
+	}
+	return t.errorf("invalid character %s %s", quoteChar(t.src[t.pos]), context)
+}
+
+// hex4 decodes the four hexadecimal digits at the start of b.
+func hex4(b []byte) (rune, bool) {
+	if len(b) < 4 {
+		return 0, false
+	}
+
+	var r rune
+	for _, c := range b[:4] {
+		switch {
+		case c >= '0' && c <= '9':
+			c -= '0'
+		case c >= 'a' && c <= 'f':
+			c = c - 'a' + 10
+		case c >= 'A' && c <= 'F':
+			c = c - 'A' + 10
+		default:
+			return 0, false
+		}
+		r = r<<4 | rune(c)
+	}
+
+	return r, true
+}
+
+// quoteChar formats c for use in an error message.
+func quoteChar(c byte) string {
+	if c == '\'' {
+		return `'\''`
+	}
+	if c == '"' {
+		return `'"'`
+	}
+	if c < utf8.RuneSelf && c >= 0x20 {
+		return "'" + string(c) + "'"
+	}
+	return fmt.Sprintf("%#02x", c)
+}
@@ -0,0 +1,161 @@
+package jcs
+
+import (
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTransform(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		// see: [3.2.2. Serialization of Primitive Data Types](https://www.rfc-editor.org/rfc/rfc8785#section-3.2.2)
+		// The sample writes 333333333.3333333 as 333333333.33333329, which is
+		// not the value of the double and is rejected, see RoundedNumber.
+		{
+			name: "RFC8785Sample",
+			in: `{
+  "numbers": [333333333.3333333, 1E30, 4.50,
+              2e-3, 0.000000000000000000000000001],
+  "string": "\u20ac$\u000F\u000aA'\u0042\u0022\u005c\\\"\/",
+  "literals": [null, true, false]
+}`,
+			want: `{"literals":[null,true,false],"numbers":[333333333.3333333,1e+30,4.5,0.002,1e-27],"string":"€$\u000f\nA'B\"\\\\\"/"}`,
+		},
+		{name: "Literals", in: ` true `, want: `true`},
+		{name: "EmptyObject", in: "{ \n }", want: `{}`},
+		{name: "EmptyArray", in: "[\t]", want: `[]`},
+		{name: "NestedSorting", in: `{"b":{"d":1,"c":2},"a":[{"z":0,"y":1}]}`, want: `{"a":[{"y":1,"z":0}],"b":{"c":2,"d":1}}`},
+		{name: "UTF16Ordering", in: `{"ﬁ":1,"😀":2,"a":3,"\u0080":4}`, want: "{\"a\":3,\"\u0080\":4,\"😀\":2,\"ﬁ\":1}"},
+		{name: "EscapedKeyOrdering", in: `{"\u0062":1,"\u0061":2}`, want: `{"a":2,"b":1}`},
+		{name: "SurrogatePairEscape", in: `"\ud83d\ude00\uFB01"`, want: `"😀ﬁ"`},
+		{name: "ControlEscapes", in: `"\u0008\u0009\u000a\u000c\u000d\u001f\u007f"`, want: "\"\\b\\t\\n\\f\\r\\u001f\u007f\""},
+		{name: "ReplacementCharacter", in: "\"�\"", want: "\"�\""},
+		{name: "NumberNormalization", in: `[-0, 0.0, 1.0, 1e0, -1.5E+2, 1e21, 1e-7]`, want: `[0,0,1,1,-150,1e+21,1e-7]`},
+		{name: "ExactIntegers", in: `[9007199254740992, 100000000000000000000, 999999999999999900000, -18446744073709551616]`, want: `[9007199254740992,100000000000000000000,999999999999999900000,-18446744073709552000]`},
+		{name: "MemberWithWhitespace", in: `{ "a" : 1 , "b" : [ 1 , 2 ] }`, want: `{"a":1,"b":[1,2]}`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			out, err := Transform(nil, []byte(tc.in))
+			Equals(t, nil, err)
+			Equals(t, tc.want, string(out))
+		})
+	}
+}
+
+func TestTransformErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		wantErr error
+		offset  int64
+		line    int
+		column  int
+	}{
+		{name: "Empty", in: ``, offset: 0, line: 1, column: 1},
+		{name: "TrailingComma", in: `[1,]`, offset: 3, line: 1, column: 4},
+		{name: "TrailingData", in: `{} {}`, offset: 3, line: 1, column: 4},
+		{name: "UnterminatedString", in: `"abc`, offset: 4, line: 1, column: 5},
+		{name: "MissingColon", in: "{\n  \"a\" 1}", offset: 8, line: 2, column: 7},
+		{name: "UnquotedKey", in: `{a:1}`, offset: 1, line: 1, column: 2},
+		{name: "SingleQuotes", in: `'a'`, offset: 0, line: 1, column: 1},
+		{name: "InvalidLiteral", in: `[tru]`, offset: 1, line: 1, column: 2},
+		{name: "InvalidEscape", in: `"\x"`, offset: 1, line: 1, column: 2},
+		{name: "ShortUnicodeEscape", in: `"\u12"`, offset: 1, line: 1, column: 2},
+		{name: "RawControlCharacter", in: "\"a\tb\"", offset: 2, line: 1, column: 3},
+		{name: "InvalidNumber", in: `[01]`, wantErr: ErrInvalidNumber, offset: 1, line: 1, column: 2},
+		{name: "DuplicateKey", in: "{\"a\":1,\n\"b\":2,\n\"a\":3}", wantErr: ErrDuplicateKey, offset: 15, line: 3, column: 1},
+		{name: "DuplicateEscapedKey", in: `{"\u0061":1,"a":2}`, wantErr: ErrDuplicateKey, offset: 12, line: 1, column: 13},
+		{name: "NestedDuplicateKey", in: `[{"x":{"k":1,"k":1}}]`, wantErr: ErrDuplicateKey, offset: 13, line: 1, column: 14},
+		{name: "LoneHighSurrogate", in: `"\ud83d"`, wantErr: ErrInvalidUTF8, offset: 1, line: 1, column: 2},
+		{name: "LoneLowSurrogate", in: `"\ude00"`, wantErr: ErrInvalidUTF8, offset: 1, line: 1, column: 2},
+		{name: "HighSurrogateNoLow", in: `"\ud83dA"`, wantErr: ErrInvalidUTF8, offset: 1, line: 1, column: 2},
+		{name: "InvalidUTF8", in: "\"a\xffb\"", wantErr: ErrInvalidUTF8, offset: 2, line: 1, column: 3},
+		{name: "EncodedSurrogate", in: "\"\xed\xa0\x80\"", wantErr: ErrInvalidUTF8, offset: 1, line: 1, column: 2},
+		{name: "UnsafeInteger", in: `{"id":12345678901234567890}`, wantErr: ErrNumberOOR, offset: 6, line: 1, column: 7},
+		{name: "RoundedLargeInteger", in: `[18446744073709551617]`, wantErr: ErrNumberOOR, offset: 1, line: 1, column: 2},
+		{name: "Overflow", in: `1e400`, wantErr: ErrNumberOOR, offset: 0, line: 1, column: 1},
+		{name: "Precision", in: `[0.10000000000000000001]`, wantErr: ErrNumberPrecision, offset: 1, line: 1, column: 2},
+		{name: "RoundedNumber", in: `{"numbers": [333333333.33333329]}`, wantErr: ErrNumberPrecision, offset: 13, line: 1, column: 14},
+		{name: "RoundedInteger", in: `[9007199254740993.0]`, wantErr: ErrNumberPrecision, offset: 1, line: 1, column: 2},
+		{name: "RoundedHalf", in: `[9007199254740993.5]`, wantErr: ErrNumberPrecision, offset: 1, line: 1, column: 2},
+		{name: "RoundedToOne", in: `[1.0000000000000001]`, wantErr: ErrNumberPrecision, offset: 1, line: 1, column: 2},
+		{name: "RoundedToShortest", in: `[0.30000000000000001]`, wantErr: ErrNumberPrecision, offset: 1, line: 1, column: 2},
+		{name: "TooDeep", in: strings.Repeat("[", maxNestingDepth+1), offset: maxNestingDepth, line: 1, column: maxNestingDepth + 1},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dst := []byte("prefix")
+			out, err := Transform(dst, []byte(tc.in))
+			Equals(t, "prefix", string(out))
+
+			var syntaxErr *SyntaxError
+			Equals(t, true, errors.As(err, &syntaxErr))
+			Equals(t, tc.wantErr, syntaxErr.Err)
+			Equals(t, tc.offset, syntaxErr.Offset)
+			Equals(t, tc.line, syntaxErr.Line)
+			Equals(t, tc.column, syntaxErr.Column)
+		})
+	}
+}
+
+// TestTransformMatchesAppend checks that canonicalizing the encoding/json
+// output of a value gives the same bytes as Append on the value itself.
+func TestTransformMatchesAppend(t *testing.T) {
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	for i := 0; i < 100; i++ {
+		m := randomMap(50, rng)
+		m["nested"] = []any{randomMap(5, rng), randomString(10, rng), nil}
+		m[randomString(5, rng)] = randomString(20, rng)
+
+		want, err := Append(nil, m)
+		Equals(t, nil, err)
+
+		src, err := json.MarshalIndent(m, "", "  ")
+		Equals(t, nil, err)
+
+		got, err := Transform(nil, src)
+		Equals(t, nil, err)
+		Equals(t, string(want), string(got))
+	}
+}
+
+func BenchmarkTransform(b *testing.B) {
+	b.ReportAllocs()
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	for _, size := range benchSizes() {
+		src, err := json.Marshal(randomMap(size, rng))
+		if err != nil {
+			b.Fatal(err)
+		}
+		buf := make([]byte, 0, len(src))
+
+		b.Run(
+			"Size="+strconv.Itoa(size),
+			func(b *testing.B) {
+				b.SetBytes(int64(len(src)))
+
+				b.ResetTimer()
+				for b.Loop() {
+					_, err := Transform(buf[:0], src)
+					if err != nil {
+						b.Fatal(err)
+						return
+					}
+				}
+			},
+		)
+	}
+}
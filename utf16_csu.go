@@ -1,6 +1,8 @@
 package jcs
 
 import (
+	"cmp"
+	"strings"
 	"unicode/utf16"
 	"unicode/utf8"
 )
@@ -22,6 +24,10 @@ type kv struct {
 	// len is the length of the key in terms of UTF-16 code units (i.e., number of 16-bit units).
 	// This accounts for surrogate pairs and ensures proper indexing.
 	len int
+
+	// idx is the position of the member value in a slice kept alongside the
+	// keys, used when the value cannot be looked up by raw (e.g. appendMap).
+	idx int
 }
 
 // appendUTF16 converts a UTF-8 encoded string into UTF-16 code units.
@@ -80,3 +86,55 @@ func appendUTF16(buf []uint16, s string) ([]uint16, int, error) {
 
 	return buf, len(buf) - start, nil
 }
+
+// compareUTF16 compares two UTF-8 encoded strings by their UTF-16 code units,
+// which is the member ordering mandated by RFC 8785 section 3.2.3.
+//
+// It yields the same ordering as sorting the buffers produced by appendUTF16
+// (as appendObject does) but walks both strings in place, so it can be used
+// where keys are known ahead of time, such as struct field names, without
+// building a shared UTF-16 buffer. The result is -1 if a sorts before b, +1 if
+// it sorts after and 0 if both strings are equal.
+//
+// a and b are assumed to be valid UTF-8. Invalid bytes compare as U+FFFD,
+// and strings that differ only in them are ordered by their bytes, so that
+// the order stays total and sorting them is deterministic.
+func compareUTF16(a, b string) int {
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		ra, na := utf8.DecodeRuneInString(a[i:])
+		rb, nb := utf8.DecodeRuneInString(b[j:])
+
+		if ra != rb {
+			// expand supplementary characters into their surrogate pair so
+			// that e.g. U+1F600 (0xD83D...) sorts before U+FB01 (0xFB01).
+			ha, la := utf16Units(ra)
+			hb, lb := utf16Units(rb)
+			if ha != hb {
+				return cmp.Compare(ha, hb)
+			}
+			return cmp.Compare(la, lb)
+		}
+
+		i += na
+		j += nb
+	}
+
+	if c := cmp.Compare(len(a)-i, len(b)-j); c != 0 {
+		return c
+	}
+
+	// same code units, e.g. "\xff" and "\xfe" both decode to U+FFFD
+	return strings.Compare(a, b)
+}
+
+// utf16Units returns the UTF-16 code units of r. Runes in the Basic
+// Multilingual Plane are returned as a single unit with a zero low unit.
+func utf16Units(r rune) (uint16, uint16) {
+	if r < 0x10_000 {
+		return uint16(r), 0
+	}
+
+	h, l := utf16.EncodeRune(r)
+	return uint16(h), uint16(l)
+}
@@ -61,3 +61,30 @@ func BenchmarkAppendUTF16(b *testing.B) {
 		)
 	}
 }
+
+func TestCompareUTF16(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want int
+	}{
+		{"Equal", "abc", "abc", 0},
+		{"Empty", "", "a", -1},
+		{"Prefix", "ab", "abc", -1},
+		{"ASCII", "b", "a", 1},
+		{"MixedCase", "A", "a", -1},
+		{"SurrogateBeforeHighBMP", "😀", "ﬁ", -1},
+		{"SurrogatePairs", "😀", "😁", -1},
+		{"BMPBeforeSurrogate", "a", "😀", -1},
+		{"InvalidUTF8Bytes", "a\xfe", "a\xff", -1},
+		{"InvalidUTF8AndReplacement", "a\xff", "a\uFFFD", 1},
+		{"InvalidUTF8BeforeLonger", "\xff", "\xfeb", -1},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			Equals(t, tc.want, compareUTF16(tc.a, tc.b))
+			Equals(t, -tc.want, compareUTF16(tc.b, tc.a))
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package jcs
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode/utf8"
+)
+
+// Violation describes a single value that prevents a Go value from being
+// encoded as RFC 8785 canonical JSON, or that breaks a restriction of
+// I-JSON (RFC 7493), as reported by Validate.
+type Violation struct {
+	// Path is the RFC 6901 JSON Pointer of the offending value, e.g.
+	// "/orders/12/price", or "" for the validated value itself. For an
+	// invalid member name it points to the object holding the member.
+	Path string
+
+	// Type is the Go type of the offending value.
+	Type reflect.Type
+
+	// Err is the error describing the violation, such as ErrNaN,
+	// ErrNumberOOR, ErrInvalidUTF8, ErrUnsupportedType or ErrNoncharacter.
+	Err error
+}
+
+func (v Violation) String() string {
+	msg := strings.TrimPrefix(v.Err.Error(), "jcs: ")
+	if v.Type == nil {
+		return fmt.Sprintf("%s at %q", msg, v.Path)
+	}
+	return fmt.Sprintf("%s at %q (%s)", msg, v.Path, v.Type)
+}
+
+// Validate walks v and reports every value that Append would reject,
+// instead of stopping at the first one like Append does.
+//
+// Validation runs the encoder itself, so Validate and Append never
+// disagree: v can be encoded if and only if Validate reports no violation
+// other than ErrNoncharacter. The reported problems include:
+//
+//   - NaN and ±Inf numbers (ErrNaN, ErrInf);
+//   - integers beyond ±MaxSafeNumber and json.Number values that a double
+//     would round (ErrNumberOOR, ErrNumberPrecision,
+//     ErrInvalidNumber);
+//   - invalid UTF-8 and surrogate code points in strings and member names
+//     (ErrInvalidUTF8);
+//   - values without a JSON representation (ErrUnsupportedType);
+//   - errors returned by marshalers, and by Transform for json.RawMessage
+//     values, within the value they were called for.
+//
+// In addition, Validate reports strings and member names containing Unicode
+// noncharacters (ErrNoncharacter), which I-JSON forbids but Append accepts.
+//
+// Violations are listed in the order Append visits the values: array
+// elements by index and object members by their canonical order. An element
+// of a value implementing Marshaler or json.Marshaler is not visited on its
+// own; the error of the marshaler is reported for the value as a whole.
+// Validate returns nil if v is valid.
+func Validate(v any) []Violation {
+	e := encoderPool.Get().(*Encoder)
+	defer encoderPool.Put(e)
+
+	e.collect = true
+	defer func() {
+		e.collect = false
+		e.violations = nil
+	}()
+
+	if _, err := e.append(nil, v); err != nil {
+		e.violations = append(e.violations, Violation{Type: reflect.TypeOf(v), Err: err})
+	}
+
+	return e.violations
+}
+
+// checkString returns ErrMaxStringLength if s is longer than the string
+// length limit of e, and ErrNoncharacter if e collects violations and s
+// contains a Unicode noncharacter.
+func (e *Encoder) checkString(s string) error {
+	if e.maxStringLength > 0 && len(s) > e.maxStringLength {
+		return ErrMaxStringLength
+	}
+
+	if !e.collect {
+		return nil
+	}
+
+	for i := 0; i < len(s); {
+		// noncharacters are all encoded with three or four bytes
+		if s[i] < 0xEF {
+			i++
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if isNoncharacter(r) {
+			return ErrNoncharacter
+		}
+		i += size
+	}
+
+	return nil
+}
+
+// isNoncharacter reports whether r is one of the 66 Unicode noncharacters:
+// U+FDD0–U+FDEF and U+nFFFE, U+nFFFF for every plane n.
+func isNoncharacter(r rune) bool {
+	return (r >= 0xFDD0 && r <= 0xFDEF) || r&0xFFFE == 0xFFFE
+}
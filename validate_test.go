@@ -0,0 +1,121 @@
+package jcs
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+)
+
+type validateRecord struct {
+	Name  string  `json:"name"`
+	Score float64 `json:"score"`
+	Tags  []string
+}
+
+func TestValidate(t *testing.T) {
+	bad := string([]byte{0xff})
+
+	testCases := []struct {
+		name  string
+		value any
+		want  []Violation
+	}{
+		{name: "Valid", value: map[string]any{"a": []any{1, "x", nil}}, want: nil},
+		{
+			name:  "TopLevel",
+			value: math.NaN(),
+			want:  []Violation{{Path: "", Type: reflectTypeOf[float64](), Err: ErrNaN}},
+		},
+		{
+			name: "EveryViolation",
+			value: map[string]any{
+				"a": []any{math.Inf(1), 1, int64(math.MaxInt64)},
+				"b": map[string]any{"c": bad, "d": "ok", "e": make(chan int)},
+				"f": json.Number("01"),
+			},
+			want: []Violation{
+				{Path: "/a/0", Type: reflectTypeOf[float64](), Err: ErrInf},
+				{Path: "/a/2", Type: reflectTypeOf[int64](), Err: ErrNumberOOR},
+				{Path: "/b/c", Type: reflectTypeOf[string](), Err: ErrInvalidUTF8},
+				{Path: "/b/e", Type: reflectTypeOf[chan int](), Err: ErrUnsupportedType},
+				{Path: "/f", Type: reflectTypeOf[json.Number](), Err: ErrInvalidNumber},
+			},
+		},
+		{
+			name: "Structs",
+			value: []validateRecord{
+				{Name: "ok", Score: 1},
+				{Name: bad, Score: math.NaN(), Tags: []string{"x", "\ufdd0"}},
+			},
+			want: []Violation{
+				{Path: "/1/Tags/1", Type: reflectTypeOf[string](), Err: ErrNoncharacter},
+				{Path: "/1/name", Type: reflectTypeOf[string](), Err: ErrInvalidUTF8},
+				{Path: "/1/score", Type: reflectTypeOf[float64](), Err: ErrNaN},
+			},
+		},
+		{
+			name:  "MemberNames",
+			value: map[string]map[string]int{"a/b": {bad: 1, "\U0010FFFF": 2, "ok": 3}},
+			want: []Violation{
+				{Path: "/a~1b", Type: reflectTypeOf[map[string]int](), Err: ErrNoncharacter},
+				{Path: "/a~1b", Type: reflectTypeOf[map[string]int](), Err: ErrInvalidUTF8},
+			},
+		},
+		{
+			name:  "Noncharacters",
+			value: []string{"\ufffe", "\ufdef", "\U0001FFFF", "\ufdcf", "\ufffd", "\U0001FFFD"},
+			want: []Violation{
+				{Path: "/0", Type: reflectTypeOf[string](), Err: ErrNoncharacter},
+				{Path: "/1", Type: reflectTypeOf[string](), Err: ErrNoncharacter},
+				{Path: "/2", Type: reflectTypeOf[string](), Err: ErrNoncharacter},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := Validate(tc.value)
+			if tc.name == "MemberNames" {
+				// the names of an object are checked in map order
+				sortViolations(got)
+			}
+			Equals(t, tc.want, got)
+
+			// Append fails exactly when Validate reports a violation
+			// other than a noncharacter.
+			fatal := false
+			for _, v := range got {
+				fatal = fatal || !errors.Is(v.Err, ErrNoncharacter)
+			}
+			_, err := Append(nil, tc.value)
+			Equals(t, fatal, err != nil)
+		})
+	}
+}
+
+func TestValidateEncoderUnchanged(t *testing.T) {
+	// noncharacters are only reported by Validate
+	out, err := Append(nil, map[string]string{"\uffff": "\ufdd0"})
+	Equals(t, nil, err)
+	Equals(t, "{\"\uffff\":\"\ufdd0\"}", string(out))
+
+	Validate([]any{math.NaN()})
+
+	_, err = Append(nil, []any{math.NaN()})
+	Equals(t, true, errors.Is(err, ErrNaN))
+}
+
+func TestViolationString(t *testing.T) {
+	v := Violation{Path: "/a/0", Type: reflectTypeOf[float64](), Err: ErrNaN}
+	Equals(t, `cannot c14n NaN at "/a/0" (float64)`, v.String())
+}
+
+// sortViolations orders violations sharing a path by their error message.
+func sortViolations(vs []Violation) {
+	for i := 1; i < len(vs); i++ {
+		for j := i; j > 0 && vs[j].Path == vs[j-1].Path && vs[j].Err.Error() < vs[j-1].Err.Error(); j-- {
+			vs[j], vs[j-1] = vs[j-1], vs[j]
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package jcs
+
+// Verify checks that data is byte-for-byte the canonical JSON representation
+// defined by RFC 8785, i.e. that Transform would return it unchanged.
+//
+// It scans data once with the tokenizer of Transform, without decoding it or
+// building the canonical form, and stops at the first deviation:
+//
+//   - insignificant whitespace outside of strings;
+//   - object members that are not sorted by the UTF-16 code units of their
+//     names, the ordering of appendObject;
+//   - numbers that are not in the form produced by Append, e.g. "1.0",
+//     "1E3" or "-0";
+//   - escape sequences other than the ones appendString emits, e.g. "\/",
+//     "\u0041" or "\u000a" instead of "\n".
+//
+// Error handling:
+//   - Returns nil if data is canonical.
+//   - Returns a *SyntaxError carrying the offset, line and column of the
+//     first deviation. Its Err is ErrNotCanonical for valid JSON that is not
+//     in canonical form, and the matching sentinel (ErrDuplicateKey,
+//     ErrNumberOOR, ...) or nil for input that cannot be canonicalized at
+//     all.
+//
+// Verify is meant for signature verifiers that must reject any encoding of
+// a document other than its canonical one.
+func Verify(data []byte) error {
+	t := transformerPool.Get().(*transformer)
+	defer t.release()
+
+	t.src = data
+	t.verify = true
+
+	out, err := t.transform(t.scratch[:0])
+	t.scratch = out[:0]
+
+	return err
+}
+
+// IsCanonical reports whether data is valid JSON in the canonical form
+// defined by RFC 8785. See Verify for the reason of a negative answer.
+func IsCanonical(data []byte) bool {
+	return Verify(data) == nil
+}
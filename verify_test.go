@@ -0,0 +1,175 @@
+package jcs
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"math/rand"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name      string
+		in        string
+		canonical bool
+		wantErr   error
+		offset    int64
+	}{
+		{name: "Object", in: `{"a":[1,"b",true,null],"b":{"c":1.5}}`, canonical: true},
+		{name: "UTF16Order", in: `{"a":1,"😀":2,"ﬁ":3}`, canonical: true},
+		{name: "Escapes", in: `"\b\t\n\f\r\"\\\u001f\u0000"`, canonical: true},
+		{name: "Numbers", in: `[0,-1,1.5,1e+21,1e-7,0.000001,333333333.3333333]`, canonical: true},
+		{name: "LargeIntegers", in: `[9007199254740992,100000000000000000000,999999999999999900000]`, canonical: true},
+		{name: "EmptyContainers", in: `[{},[]]`, canonical: true},
+
+		{name: "LeadingWhitespace", in: ` {}`, wantErr: ErrNotCanonical, offset: 0},
+		{name: "TrailingNewline", in: "{}\n", wantErr: ErrNotCanonical, offset: 2},
+		{name: "WhitespaceAfterColon", in: `{"a": 1}`, wantErr: ErrNotCanonical, offset: 5},
+		{name: "WhitespaceInArray", in: `[1, 2]`, wantErr: ErrNotCanonical, offset: 3},
+		{name: "UnsortedMembers", in: `{"b":1,"a":2}`, wantErr: ErrNotCanonical, offset: 7},
+		{name: "UTF8Order", in: `{"ﬁ":1,"😀":2}`, wantErr: ErrNotCanonical, offset: 9},
+		{name: "NestedUnsorted", in: `[{"a":{"d":1,"c":2}}]`, wantErr: ErrNotCanonical, offset: 13},
+		{name: "DuplicateKey", in: `{"a":1,"a":2}`, wantErr: ErrDuplicateKey, offset: 7},
+		{name: "TrailingZero", in: `[1.50]`, wantErr: ErrNotCanonical, offset: 1},
+		{name: "IntegerFraction", in: `1.0`, wantErr: ErrNotCanonical, offset: 0},
+		{name: "UppercaseExponent", in: `1E21`, wantErr: ErrNotCanonical, offset: 0},
+		{name: "UnsignedExponent", in: `1e21`, wantErr: ErrNotCanonical, offset: 0},
+		{name: "MinusZero", in: `-0`, wantErr: ErrNotCanonical, offset: 0},
+		{name: "UnnecessaryExponent", in: `1e2`, wantErr: ErrNotCanonical, offset: 0},
+		{name: "EscapedSolidus", in: `"a\/b"`, wantErr: ErrNotCanonical, offset: 2},
+		{name: "EscapedLetter", in: `"\u0041"`, wantErr: ErrNotCanonical, offset: 1},
+		{name: "EscapedNonASCII", in: `"\u00e9"`, wantErr: ErrNotCanonical, offset: 1},
+		{name: "LongControlEscape", in: `"\u000a"`, wantErr: ErrNotCanonical, offset: 1},
+		{name: "UppercaseHexEscape", in: `"\u001F"`, wantErr: ErrNotCanonical, offset: 1},
+		{name: "EscapedKey", in: `{"\u0061":1}`, wantErr: ErrNotCanonical, offset: 2},
+		{name: "FirstDeviationWins", in: `{"b":1.0, "a":1}`, wantErr: ErrNotCanonical, offset: 5},
+
+		{name: "InvalidJSON", in: `{"a":}`, offset: 5},
+		{name: "UnsafeInteger", in: `9007199254740993`, wantErr: ErrNumberOOR, offset: 0},
+		{name: "ExactIntegerNotShortest", in: `18446744073709551616`, wantErr: ErrNotCanonical, offset: 0},
+		{name: "InvalidUTF8", in: "\"\xff\"", wantErr: ErrInvalidUTF8, offset: 1},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := Verify([]byte(tc.in))
+			Equals(t, tc.canonical, err == nil)
+			Equals(t, tc.canonical, IsCanonical([]byte(tc.in)))
+
+			if tc.canonical {
+				out, terr := Transform(nil, []byte(tc.in))
+				Equals(t, nil, terr)
+				Equals(t, tc.in, string(out))
+				return
+			}
+
+			var syntaxErr *SyntaxError
+			Equals(t, true, errors.As(err, &syntaxErr))
+			Equals(t, tc.wantErr, syntaxErr.Err)
+			Equals(t, tc.offset, syntaxErr.Offset)
+		})
+	}
+}
+
+// TestVerifyTransformOutput checks that the output of Transform is always
+// reported as canonical.
+func TestVerifyTransformOutput(t *testing.T) {
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	for i := 0; i < 100; i++ {
+		m := randomMap(50, rng)
+		m["nested"] = []any{randomMap(5, rng), randomString(10, rng), nil}
+		m[randomString(5, rng)] = randomString(20, rng) + "\n\"\x01"
+
+		src, err := json.MarshalIndent(m, "", "  ")
+		Equals(t, nil, err)
+		Equals(t, false, IsCanonical(src))
+
+		out, err := Transform(nil, src)
+		Equals(t, nil, err)
+		Equals(t, nil, Verify(out))
+	}
+}
+
+// TestVerifyAppendNumber checks that Verify accepts every number written
+// by Append: the values of RFC 8785 Appendix B and random doubles.
+func TestVerifyAppendNumber(t *testing.T) {
+	appendixB := map[uint64]string{
+		0x0000000000000000: "0",
+		0x8000000000000000: "0",
+		0x0000000000000001: "5e-324",
+		0x8000000000000001: "-5e-324",
+		0x7fefffffffffffff: "1.7976931348623157e+308",
+		0xffefffffffffffff: "-1.7976931348623157e+308",
+		0x4340000000000000: "9007199254740992",
+		0xc340000000000000: "-9007199254740992",
+		0x4430000000000000: "295147905179352830000",
+		0x44b52d02c7e14af5: "9.999999999999997e+22",
+		0x44b52d02c7e14af6: "1e+23",
+		0x44b52d02c7e14af7: "1.0000000000000001e+23",
+		0x444b1ae4d6e2ef4e: "999999999999999700000",
+		0x444b1ae4d6e2ef4f: "999999999999999900000",
+		0x444b1ae4d6e2ef50: "1e+21",
+		0x3eb0c6f7a0b5ed8c: "9.999999999999997e-7",
+		0x3eb0c6f7a0b5ed8d: "0.000001",
+		0x41b3de4355555553: "333333333.3333332",
+		0x41b3de4355555554: "333333333.33333325",
+		0x41b3de4355555555: "333333333.3333333",
+		0x41b3de4355555556: "333333333.3333334",
+		0x41b3de4355555557: "333333333.33333343",
+		0xbecbf647612f3696: "-0.0000033333333333333333",
+		0x43143ff3c1cb0959: "1424953923781206.2",
+	}
+
+	for b, want := range appendixB {
+		out, err := Append(nil, math.Float64frombits(b))
+		Equals(t, nil, err)
+		Equals(t, want, string(out))
+		Equals(t, nil, Verify(out))
+		Equals(t, true, IsCanonical(out))
+	}
+
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	for range 1 << 16 {
+		v := math.Float64frombits(rng.Uint64())
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			continue
+		}
+
+		out, err := Append(nil, v)
+		Equals(t, nil, err)
+		if err := Verify(out); err != nil {
+			t.Fatalf("Verify(Append(%v)) = %v, bits %016x", v, err, math.Float64bits(v))
+		}
+	}
+}
+
+func BenchmarkVerify(b *testing.B) {
+	b.ReportAllocs()
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	for _, size := range benchSizes() {
+		src, err := Append(nil, randomMap(size, rng))
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		b.Run(
+			"Size="+strconv.Itoa(size),
+			func(b *testing.B) {
+				b.SetBytes(int64(len(src)))
+
+				b.ResetTimer()
+				for b.Loop() {
+					if err := Verify(src); err != nil {
+						b.Fatal(err)
+						return
+					}
+				}
+			},
+		)
+	}
+}
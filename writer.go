@@ -0,0 +1,418 @@
+package jcs
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"unicode/utf8"
+)
+
+// Writer writes canonical JSON to an io.Writer from a sequence of tokens,
+// for producers that generate JSON piece by piece, e.g. from an iterator,
+// instead of holding it in a Go value.
+//
+// Object members may be written in any order: the Writer buffers every
+// object until its EndObject, then sorts its members by the UTF-16 code
+// units of their names, as Append does, and rejects duplicate names with
+// ErrDuplicateKey. Elements of arrays that are not nested in an object are
+// written in chunks as they are completed, like a StreamEncoder does.
+//
+// A Writer writes a single top-level value. A token that cannot appear at
+// the current position, including the start of a second top-level value,
+// is rejected with ErrInvalidToken. Errors are sticky: after the first one, every method
+// returns it and the output must be discarded.
+//
+// A Writer is not safe for concurrent use by multiple goroutines.
+type Writer struct {
+	w io.Writer
+
+	// e encodes the values passed to Value, and holds the chunk size.
+	e   *Encoder
+	buf []byte
+	err error
+
+	// stack holds the open arrays and objects, objects counts the latter.
+	stack   []writerFrame
+	objects int
+
+	// done is set once the top-level value is complete.
+	done bool
+
+	// utf16, keys and members record the member names and spans of the
+	// open objects, used as stacks like those of transformer.
+	utf16   []uint16
+	keys    []kv
+	members []member
+
+	// scratch is the copy of an object's members used while reordering.
+	scratch []byte
+}
+
+// writerFrame is an array or object opened by a Writer.
+type writerFrame struct {
+	object bool
+
+	// start is the position in buf of the opening bracket of an object,
+	// and n the number of elements or members written so far.
+	start int
+	n     int
+
+	// keyBase, utf16Base and memberBase are the lengths of the scratch
+	// stacks when the object was opened.
+	keyBase, utf16Base, memberBase int
+
+	// member is the position in buf of the member whose name was written
+	// last but whose value is not complete, or -1 when a name is expected.
+	member int
+}
+
+// NewWriter returns a Writer writing to w. The options configure how Value
+// encodes Go values, as for NewEncoder, and the chunk size, see
+// WithChunkSize.
+func NewWriter(w io.Writer, opts ...Option) *Writer {
+	e := NewEncoder(opts...)
+	if e.chunkSize == 0 {
+		e.chunkSize = defaultChunkSize
+	}
+
+	return &Writer{w: w, e: e}
+}
+
+// BeginObject starts an object, ended by EndObject.
+func (w *Writer) BeginObject() error {
+	if err := w.beginValue(); err != nil {
+		return err
+	}
+
+	w.stack = append(w.stack, writerFrame{
+		object:     true,
+		start:      len(w.buf),
+		keyBase:    len(w.keys),
+		utf16Base:  len(w.utf16),
+		memberBase: len(w.members),
+		member:     -1,
+	})
+	w.objects++
+	w.buf = append(w.buf, '{')
+
+	return nil
+}
+
+// Key writes the name of the next member of the current object, whose
+// value must follow.
+//
+// Error handling:
+//   - Returns ErrInvalidToken outside of an object or if the previous
+//     member has no value.
+//   - Returns ErrInvalidUTF8 if name is not valid UTF-8, unless invalid
+//     bytes are replaced by WithInvalidUTF8Replacement.
+//   - Returns ErrMaxStringLength if name exceeds WithMaxStringLength.
+func (w *Writer) Key(name string) error {
+	if w.err != nil {
+		return w.err
+	}
+
+	f := w.top()
+	switch {
+	case f == nil || !f.object:
+		return w.fail(fmt.Errorf("%w: Key %q outside of an object", ErrInvalidToken, name))
+	case f.member >= 0:
+		return w.fail(fmt.Errorf("%w: Key %q where a member value is expected", ErrInvalidToken, name))
+	}
+
+	if w.e.replaceInvalidUTF8 && !utf8.ValidString(name) {
+		name = toValidUTF8(name)
+	}
+
+	units := len(w.utf16)
+	utf16, n, err := appendUTF16(w.utf16, name)
+	if err != nil {
+		return w.fail(err)
+	}
+
+	member := len(w.buf)
+	if f.n > 0 {
+		member++
+		w.buf = append(w.buf, ',')
+	}
+	if w.buf, err = w.e.appendString(w.buf, name); err != nil {
+		return w.fail(err)
+	}
+	w.buf = append(w.buf, ':')
+	w.utf16 = utf16
+	f.member = member
+
+	w.keys = append(w.keys, kv{
+		raw:   name,
+		len:   n,
+		start: units,
+		idx:   len(w.members),
+	})
+
+	return nil
+}
+
+// EndObject ends the current object and writes its members sorted by name.
+//
+// Error handling:
+//   - Returns ErrInvalidToken if the current container is not an object or
+//     its last member has no value.
+//   - Returns ErrDuplicateKey if two members have the same name.
+func (w *Writer) EndObject() error {
+	if w.err != nil {
+		return w.err
+	}
+
+	f := w.top()
+	switch {
+	case f == nil || !f.object:
+		return w.fail(fmt.Errorf("%w: EndObject outside of an object", ErrInvalidToken))
+	case f.member >= 0:
+		return w.fail(fmt.Errorf("%w: EndObject where a member value is expected", ErrInvalidToken))
+	}
+
+	if keys := w.keys[f.keyBase:]; len(keys) > 1 {
+		sortKeys(keys, w.utf16)
+
+		for i := 1; i < len(keys); i++ {
+			a := w.utf16[keys[i-1].start : keys[i-1].start+keys[i-1].len]
+			b := w.utf16[keys[i].start : keys[i].start+keys[i].len]
+			if slices.Equal(a, b) {
+				return w.fail(fmt.Errorf("%w %q", ErrDuplicateKey, keys[i].raw))
+			}
+		}
+
+		// reorder the members, w.scratch[0] is the byte after '{'
+		base := f.start + 1
+		w.scratch = append(w.scratch[:0], w.buf[base:]...)
+		w.buf = w.buf[:base]
+		for i, k := range keys {
+			if i > 0 {
+				w.buf = append(w.buf, ',')
+			}
+			m := w.members[k.idx]
+			w.buf = append(w.buf, w.scratch[m.start-base:m.end-base]...)
+		}
+	}
+	w.buf = append(w.buf, '}')
+
+	clear(w.keys[f.keyBase:])
+	w.keys = w.keys[:f.keyBase]
+	w.utf16 = w.utf16[:f.utf16Base]
+	w.members = w.members[:f.memberBase]
+
+	w.stack = w.stack[:len(w.stack)-1]
+	w.objects--
+
+	return w.endValue()
+}
+
+// BeginArray starts an array, ended by EndArray.
+func (w *Writer) BeginArray() error {
+	if err := w.beginValue(); err != nil {
+		return err
+	}
+
+	w.stack = append(w.stack, writerFrame{start: len(w.buf), member: -1})
+	w.buf = append(w.buf, '[')
+
+	return nil
+}
+
+// EndArray ends the current array. It returns ErrInvalidToken if the
+// current container is not an array.
+func (w *Writer) EndArray() error {
+	if w.err != nil {
+		return w.err
+	}
+
+	if f := w.top(); f == nil || f.object {
+		return w.fail(fmt.Errorf("%w: EndArray outside of an array", ErrInvalidToken))
+	}
+
+	w.buf = append(w.buf, ']')
+	w.stack = w.stack[:len(w.stack)-1]
+
+	return w.endValue()
+}
+
+// String writes s as a JSON string. It returns ErrInvalidUTF8 if s is not
+// valid UTF-8.
+func (w *Writer) String(s string) error {
+	if err := w.beginValue(); err != nil {
+		return err
+	}
+
+	var err error
+	if w.buf, err = w.e.appendString(w.buf, s); err != nil {
+		return w.fail(err)
+	}
+
+	return w.endValue()
+}
+
+// Number writes v as a canonical JSON number. It returns ErrNaN or ErrInf
+// for values that have no JSON representation.
+func (w *Writer) Number(v float64) error {
+	if err := w.beginValue(); err != nil {
+		return err
+	}
+
+	var err error
+	if w.buf, err = w.e.appendFloat(w.buf, v); err != nil {
+		return w.fail(err)
+	}
+
+	return w.endValue()
+}
+
+// Int writes v as a canonical JSON number. It returns ErrNumberOOR if v is
+// beyond ±MaxSafeNumber.
+func (w *Writer) Int(v int64) error {
+	if err := w.beginValue(); err != nil {
+		return err
+	}
+
+	var err error
+	if isNumberOOR(v) {
+		w.buf, err = w.e.appendLargeInt(w.buf, v)
+	} else {
+		w.buf, err = appendNumber(w.buf, float64(v))
+	}
+	if err != nil {
+		return w.fail(err)
+	}
+
+	return w.endValue()
+}
+
+// Bool writes true or false.
+func (w *Writer) Bool(v bool) error {
+	if err := w.beginValue(); err != nil {
+		return err
+	}
+
+	if v {
+		w.buf = append(w.buf, 't', 'r', 'u', 'e')
+	} else {
+		w.buf = append(w.buf, 'f', 'a', 'l', 's', 'e')
+	}
+
+	return w.endValue()
+}
+
+// Null writes null.
+func (w *Writer) Null() error {
+	if err := w.beginValue(); err != nil {
+		return err
+	}
+
+	w.buf = append(w.buf, 'n', 'u', 'l', 'l')
+
+	return w.endValue()
+}
+
+// Value writes the canonical JSON representation of v, encoded like
+// Encoder.Append does with the options of w. It is the way to write
+// json.Number, json.RawMessage or any other Go value as a single token.
+func (w *Writer) Value(v any) error {
+	if err := w.beginValue(); err != nil {
+		return err
+	}
+
+	var err error
+	if w.buf, err = w.e.Append(w.buf, v); err != nil {
+		return w.fail(err)
+	}
+
+	return w.endValue()
+}
+
+// Close reports ErrInvalidToken if an array or object has not been ended.
+// A complete top-level value has already been written; Close does not
+// close the underlying io.Writer.
+func (w *Writer) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+
+	if n := len(w.stack); n > 0 {
+		return w.fail(fmt.Errorf("%w: %d unclosed arrays or objects", ErrInvalidToken, n))
+	}
+
+	return nil
+}
+
+// top returns the innermost open container, or nil at the top level.
+func (w *Writer) top() *writerFrame {
+	if len(w.stack) == 0 {
+		return nil
+	}
+
+	return &w.stack[len(w.stack)-1]
+}
+
+// beginValue checks that a value can be written at the current position
+// and writes the separator before an array element.
+func (w *Writer) beginValue() error {
+	if w.err != nil {
+		return w.err
+	}
+
+	f := w.top()
+	switch {
+	case f == nil:
+		if w.done {
+			return w.fail(fmt.Errorf("%w: second top-level value", ErrInvalidToken))
+		}
+	case f.object:
+		if f.member < 0 {
+			return w.fail(fmt.Errorf("%w: value where a member name is expected", ErrInvalidToken))
+		}
+	case f.n > 0:
+		w.buf = append(w.buf, ',')
+	}
+
+	return nil
+}
+
+// endValue records the value that was just written in its container, and
+// writes the buffer once it holds a complete top-level value, or a chunk
+// when no object is open.
+func (w *Writer) endValue() error {
+	f := w.top()
+	if f == nil {
+		w.done = true
+		return w.flush()
+	}
+
+	if f.object {
+		w.members = append(w.members, member{start: f.member, end: len(w.buf)})
+		f.member = -1
+	}
+	f.n++
+
+	// the members of open objects may still be reordered
+	if w.objects == 0 && len(w.buf) >= w.e.chunkSize {
+		return w.flush()
+	}
+
+	return nil
+}
+
+// flush writes the buffer to the underlying io.Writer.
+func (w *Writer) flush() error {
+	_, err := w.w.Write(w.buf)
+	w.buf = w.buf[:0]
+	if err != nil {
+		return w.fail(err)
+	}
+
+	return nil
+}
+
+// fail records err as the error of w and returns it.
+func (w *Writer) fail(err error) error {
+	w.err = err
+	return err
+}
@@ -0,0 +1,206 @@
+package jcs
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math"
+	"strconv"
+	"testing"
+)
+
+// token is a call on a Writer.
+type token func(w *Writer) error
+
+func beginObject(w *Writer) error { return w.BeginObject() }
+func endObject(w *Writer) error   { return w.EndObject() }
+func beginArray(w *Writer) error  { return w.BeginArray() }
+func endArray(w *Writer) error    { return w.EndArray() }
+func null(w *Writer) error        { return w.Null() }
+
+func key(name string) token { return func(w *Writer) error { return w.Key(name) } }
+func str(s string) token    { return func(w *Writer) error { return w.String(s) } }
+func num(v float64) token   { return func(w *Writer) error { return w.Number(v) } }
+func integer(v int64) token { return func(w *Writer) error { return w.Int(v) } }
+func boolean(v bool) token  { return func(w *Writer) error { return w.Bool(v) } }
+func value(v any) token     { return func(w *Writer) error { return w.Value(v) } }
+
+func TestWriter(t *testing.T) {
+	testCases := []struct {
+		name    string
+		tokens  []token
+		want    string
+		wantErr error
+	}{
+		{name: "Scalar", tokens: []token{str("hi")}, want: `"hi"`},
+		{name: "EmptyObject", tokens: []token{beginObject, endObject}, want: `{}`},
+		{name: "EmptyArray", tokens: []token{beginArray, endArray}, want: `[]`},
+		{name: "Array", tokens: []token{beginArray, integer(1), num(2.50), boolean(false), null, str("x"), endArray}, want: `[1,2.5,false,null,"x"]`},
+		{
+			name:   "SortedMembers",
+			tokens: []token{beginObject, key("b"), integer(1), key("a"), boolean(true), key("\U0001F600"), null, key("ﬁ"), null, endObject},
+			want:   "{\"a\":true,\"b\":1,\"\U0001F600\":null,\"ﬁ\":null}",
+		},
+		{
+			name: "Nested",
+			tokens: []token{
+				beginObject,
+				key("z"), beginArray, beginObject, key("y"), integer(2), key("x"), integer(1), endObject, endArray,
+				key("a"), beginObject, key("d"), beginArray, endArray, key("c"), beginObject, endObject, endObject,
+				endObject,
+			},
+			want: `{"a":{"c":{},"d":[]},"z":[{"x":1,"y":2}]}`,
+		},
+		{
+			name:   "Value",
+			tokens: []token{beginObject, key("n"), value(json.Number("1.0")), key("m"), value(map[string]int{"b": 2, "a": 1}), key("r"), value(json.RawMessage(`[ 1E2 ]`)), endObject},
+			want:   `{"m":{"a":1,"b":2},"n":1,"r":[100]}`,
+		},
+
+		{name: "ErrDuplicateKey", tokens: []token{beginObject, key("a"), integer(1), key("b"), integer(2), key("a"), integer(3), endObject}, wantErr: ErrDuplicateKey},
+		{name: "ErrKeyAtTopLevel", tokens: []token{key("a")}, wantErr: ErrInvalidToken},
+		{name: "ErrKeyInArray", tokens: []token{beginArray, key("a")}, wantErr: ErrInvalidToken},
+		{name: "ErrKeyAfterKey", tokens: []token{beginObject, key("a"), key("b")}, wantErr: ErrInvalidToken},
+		{name: "ErrValueWithoutKey", tokens: []token{beginObject, integer(1)}, wantErr: ErrInvalidToken},
+		{name: "ErrEndObjectWithoutValue", tokens: []token{beginObject, key("a"), endObject}, wantErr: ErrInvalidToken},
+		{name: "ErrEndObjectInArray", tokens: []token{beginArray, endObject}, wantErr: ErrInvalidToken},
+		{name: "ErrEndArrayInObject", tokens: []token{beginObject, endArray}, wantErr: ErrInvalidToken},
+		{name: "ErrEndArrayAtTopLevel", tokens: []token{endArray}, wantErr: ErrInvalidToken},
+		{name: "ErrInvalidUTF8Key", tokens: []token{beginObject, key("\xff")}, wantErr: ErrInvalidUTF8},
+		{name: "ErrInvalidUTF8", tokens: []token{beginArray, str("\xff")}, wantErr: ErrInvalidUTF8},
+		{name: "ErrNaN", tokens: []token{num(math.NaN())}, wantErr: ErrNaN},
+		{name: "ErrNumberOOR", tokens: []token{integer(MaxSafeNumber + 1)}, wantErr: ErrNumberOOR},
+		{name: "ErrValue", tokens: []token{value(func() {})}, wantErr: ErrUnsupportedType},
+		{name: "ErrSecondTopLevelValue", tokens: []token{integer(1), str("x")}, wantErr: ErrInvalidToken},
+		{name: "ErrSecondTopLevelArray", tokens: []token{beginArray, endArray, beginArray}, wantErr: ErrInvalidToken},
+		{name: "ErrSecondTopLevelObject", tokens: []token{beginObject, endObject, beginObject}, wantErr: ErrInvalidToken},
+		{name: "ErrSticky", tokens: []token{beginArray, endObject, endArray}, wantErr: ErrInvalidToken},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := NewWriter(&buf)
+
+			var err error
+			for _, tok := range tc.tokens {
+				if err = tok(w); err != nil {
+					break
+				}
+			}
+			if err == nil {
+				err = w.Close()
+			}
+
+			Equals(t, true, errors.Is(err, tc.wantErr))
+			if tc.wantErr == nil {
+				Equals(t, tc.want, buf.String())
+			} else {
+				// errors are sticky
+				Equals(t, err, w.Null())
+				Equals(t, err, w.Close())
+			}
+		})
+	}
+}
+
+func TestWriterClose(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+
+	Equals(t, nil, w.BeginArray())
+	Equals(t, nil, w.Int(1))
+	Equals(t, true, errors.Is(w.Close(), ErrInvalidToken))
+	Equals(t, "", buf.String())
+}
+
+func TestWriterOptions(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf, WithLargeNumbersAsStrings(), WithBytesFormat(BytesHex))
+
+	Equals(t, nil, w.BeginObject())
+	Equals(t, nil, w.Key("id"))
+	Equals(t, nil, w.Int(math.MaxInt64))
+	Equals(t, nil, w.Key("hash"))
+	Equals(t, nil, w.Value([]byte{0xca, 0xfe}))
+	Equals(t, nil, w.EndObject())
+	Equals(t, `{"hash":"cafe","id":"9223372036854775807"}`, buf.String())
+}
+
+func TestWriterKeyPolicies(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf, WithInvalidUTF8Replacement())
+
+	Equals(t, nil, w.BeginObject())
+	Equals(t, nil, w.Key("a\xffb"))
+	Equals(t, nil, w.String("\xff"))
+	Equals(t, nil, w.EndObject())
+	Equals(t, "{\"a\ufffdb\":\"\ufffd\"}", buf.String())
+
+	buf.Reset()
+	w = NewWriter(&buf, WithMaxStringLength(3))
+	Equals(t, nil, w.BeginObject())
+	Equals(t, nil, w.Key("abc"))
+	Equals(t, nil, w.Int(1))
+	Equals(t, ErrMaxStringLength, w.Key("abcd"))
+	Equals(t, ErrMaxStringLength, w.Close())
+	Equals(t, "", buf.String())
+}
+
+func TestWriterChunks(t *testing.T) {
+	// elements of a top-level array are written as they are completed,
+	// objects only once they are ended
+	var w chunkRecorder
+	tw := NewWriter(&w, WithChunkSize(16))
+
+	var want []map[string]any
+	Equals(t, nil, tw.BeginArray())
+	for i := range 1000 {
+		Equals(t, nil, tw.BeginObject())
+		Equals(t, nil, tw.Key("v"))
+		Equals(t, nil, tw.Int(int64(i)))
+		Equals(t, nil, tw.Key("k"))
+		Equals(t, nil, tw.String(strconv.Itoa(i)))
+		Equals(t, nil, tw.EndObject())
+		want = append(want, map[string]any{"k": strconv.Itoa(i), "v": i})
+	}
+	Equals(t, true, w.writes > 100)
+	Equals(t, nil, tw.EndArray())
+	Equals(t, nil, tw.Close())
+
+	wantJSON, _ := Append(nil, want)
+	Equals(t, string(wantJSON), w.String())
+	Equals(t, true, w.longest < 64)
+}
+
+func TestWriterWriteError(t *testing.T) {
+	w := NewWriter(&failingWriter{n: 3}, WithChunkSize(1))
+
+	Equals(t, nil, w.BeginArray())
+	Equals(t, nil, w.Int(1))
+	Equals(t, errWrite, w.Int(2))
+	Equals(t, errWrite, w.EndArray())
+}
+
+func BenchmarkWriter(b *testing.B) {
+	b.ReportAllocs()
+
+	var buf bytes.Buffer
+	for b.Loop() {
+		buf.Reset()
+		w := NewWriter(&buf)
+		_ = w.BeginArray()
+		for i := range 100 {
+			_ = w.BeginObject()
+			_ = w.Key("name")
+			_ = w.String("item")
+			_ = w.Key("id")
+			_ = w.Int(int64(i))
+			_ = w.EndObject()
+		}
+		_ = w.EndArray()
+		if err := w.Close(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}